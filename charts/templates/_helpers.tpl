@@ -91,3 +91,17 @@ Create the name of the service account to use
     {{- fail (printf "Invalid intervalInMinutes: %v. Must be a non-zero positive number." .) -}}
   {{- end -}}
 {{- end -}}
+
+{{- define "validate.pcsApiVersion" -}}
+  {{- $validValues := list "v3" "v4" -}}
+  {{- if not (has . $validValues) -}}
+    {{- fail (printf "Invalid pcsApiVersion: %s. Must be one of: %v" . $validValues) -}}
+  {{- end -}}
+{{- end -}}
+
+{{- define "validate.proxyType" -}}
+  {{- $validValues := list "default" "direct" "manual" -}}
+  {{- if not (has . $validValues) -}}
+    {{- fail (printf "Invalid proxy type: %s. Must be one of: %v" . $validValues) -}}
+  {{- end -}}
+{{- end -}}
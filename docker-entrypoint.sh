@@ -10,7 +10,7 @@ if [[ -e /dev/sgx_enclave && ! -e /dev/sgx/enclave ]]; then
 fi
 
 if [ "$1" = 'cc-intel-platform-registration' ]; then
-
+  shift
   exec cc-intel-platform-registration "$@"
 fi
 
@@ -1,21 +1,12 @@
+// Package mpmanagement reads and writes the SGX registration UEFI variables. It wraps the Intel MP management
+// library when built with cgo, and simulates the UEFI variables with files when built without cgo or with the
+// nosgx build tag.
 package mpmanagement
 
-/*
-#cgo CXXFLAGS: -std=c++17
-#cgo LDFLAGS: -lmp_management -lstdc++
-
-#include <stdlib.h>
-#include "../../../third_party/mp_management/src/include/c_wrapper/mp_management.h"
-*/
-import "C"
-
-import (
-	"fmt"
-)
-
 // MPManagement constants
 const (
-	MPMaxRequestSize = 1024 * 56
+	MPMaxRequestSize  = 1024 * 56
+	MPMaxResponseSize = 1024 * 30
 
 	MPResultCodeSuccess            = 0
 	MPResultNoPendingData          = 1
@@ -32,51 +23,33 @@ const (
 	MPResultInsufficientPrivileges = 12
 )
 
-// MPManagement represents the Go wrapper for the mp_management functions
-type MPManagement struct {
-	initialized bool
-}
-
-type PlatformManifest []byte
-
-// NewMPManagement creates a new instance of MPManagement
-func NewMPManagement() *MPManagement {
+// RequestType is the type of the pending request generated by the BIOS
+type RequestType int
 
-	C.mp_management_init()
-	return &MPManagement{initialized: true}
-}
+// Pending request types
+const (
+	RequestTypeRegistration RequestType = 0
+	RequestTypeAddPackage   RequestType = 1
+	RequestTypeNone         RequestType = 2
+)
 
-// Close terminates the MPManagement instance
-func (mp *MPManagement) Close() {
-	if mp.initialized {
-		C.mp_management_terminate()
-		mp.initialized = false
+func (t RequestType) String() string {
+	switch t {
+	case RequestTypeRegistration:
+		return "PlatformManifest"
+	case RequestTypeAddPackage:
+		return "AddPackage"
+	case RequestTypeNone:
+		return "None"
+	default:
+		return "Unknown"
 	}
 }
 
-// GetPlatformManifest retrieves the platform manifest by reading the UEFI SgxRegistrationServerRequest
-func (mp *MPManagement) GetPlatformManifest() (PlatformManifest, error) {
-	var size C.uint16_t = MPMaxRequestSize
-	buffer := make([]byte, size)
-
-	operation_result := C.mp_management_get_platform_manifest((*C.uint8_t)(&buffer[0]), &size)
-
-	if operation_result != MPResultCodeSuccess {
-		return nil, fmt.Errorf("failed to get platform manifest uefi variable: %s", getErrorDescription(int(operation_result)))
-	}
+type PlatformManifest []byte
 
-	return buffer[:size], nil
-}
-
-// IsMachineRegistered retrieves the machine registration status by reading the UEFI SgxRegistrationStatus.SgxRegistrationComplete variable flag
-func (mp *MPManagement) IsMachineRegistered() (bool, error) {
-	var status C.MpMachineRegistrationStatus
-	operation_result := C.mp_management_get_registration_status(&status)
-	if operation_result != MPResultCodeSuccess {
-		return false, fmt.Errorf("failed to get registration status uefi variable: %s", getErrorDescription(int(operation_result)))
-	}
-	return status == C.MP_MACHINE_REGISTERED, nil
-}
+// AddPackageRequest is the request generated by the BIOS to register an added or replaced CPU package
+type AddPackageRequest []byte
 
 func getErrorDescription(operation_result int) string {
 	switch operation_result {
@@ -110,12 +83,3 @@ func getErrorDescription(operation_result int) string {
 		return "Unknown Error"
 	}
 }
-
-// CompleteMachineRegistrationStatus sets the UEFI SgxRegistrationStatus.SgxRegistrationComplete flag to true
-func (mp *MPManagement) CompleteMachineRegistrationStatus() error {
-	operation_result := C.mp_management_set_registration_status_as_complete()
-	if operation_result != MPResultCodeSuccess {
-		return fmt.Errorf("failed to set the registration status uefi variable : %s", getErrorDescription(int(operation_result)))
-	}
-	return nil
-}
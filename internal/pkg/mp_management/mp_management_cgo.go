@@ -0,0 +1,108 @@
+//go:build cgo && !nosgx
+
+package mpmanagement
+
+/*
+#cgo CXXFLAGS: -std=c++17
+#cgo LDFLAGS: -lmp_management -lstdc++
+
+#include <stdlib.h>
+#include "../../../third_party/mp_management/src/include/c_wrapper/mp_management.h"
+*/
+import "C"
+
+import (
+	"fmt"
+)
+
+// Simulated reports whether the UEFI variables are simulated instead of accessed through the MP management library
+const Simulated = false
+
+// MPManagement represents the Go wrapper for the mp_management functions
+type MPManagement struct {
+	initialized bool
+}
+
+// NewMPManagement creates a new instance of MPManagement
+func NewMPManagement() *MPManagement {
+
+	C.mp_management_init()
+	return &MPManagement{initialized: true}
+}
+
+// Close terminates the MPManagement instance
+func (mp *MPManagement) Close() {
+	if mp.initialized {
+		C.mp_management_terminate()
+		mp.initialized = false
+	}
+}
+
+// GetPlatformManifest retrieves the platform manifest by reading the UEFI SgxRegistrationServerRequest
+func (mp *MPManagement) GetPlatformManifest() (PlatformManifest, error) {
+	var size C.uint16_t = MPMaxRequestSize
+	buffer := make([]byte, size)
+
+	operation_result := C.mp_management_get_platform_manifest((*C.uint8_t)(&buffer[0]), &size)
+
+	if operation_result != MPResultCodeSuccess {
+		return nil, fmt.Errorf("failed to get platform manifest uefi variable: %s", getErrorDescription(int(operation_result)))
+	}
+
+	return buffer[:size], nil
+}
+
+// GetPendingRequestType retrieves the type of the request stored in the UEFI SgxRegistrationServerRequest
+func (mp *MPManagement) GetPendingRequestType() (RequestType, error) {
+	var requestType C.MpRequestType
+	operation_result := C.mp_management_get_request_type(&requestType)
+	if operation_result != MPResultCodeSuccess {
+		return RequestTypeNone, fmt.Errorf("failed to get the pending request type: %s", getErrorDescription(int(operation_result)))
+	}
+	return RequestType(requestType), nil
+}
+
+// GetAddPackageRequest retrieves the AddPackage request by reading the UEFI SgxRegistrationServerRequest
+func (mp *MPManagement) GetAddPackageRequest() (AddPackageRequest, error) {
+	var size C.uint16_t = MPMaxRequestSize
+	buffer := make([]byte, size)
+
+	operation_result := C.mp_management_get_add_package_request((*C.uint8_t)(&buffer[0]), &size)
+
+	if operation_result != MPResultCodeSuccess {
+		return nil, fmt.Errorf("failed to get add package request uefi variable: %s", getErrorDescription(int(operation_result)))
+	}
+
+	return buffer[:size], nil
+}
+
+// SetServerResponse writes the Intel RS response to the UEFI SgxRegistrationServerResponse, consumed by the BIOS at the next boot
+func (mp *MPManagement) SetServerResponse(response []byte) error {
+	if len(response) == 0 || len(response) > MPMaxResponseSize {
+		return fmt.Errorf("invalid server response size %d, expected between 1 and %d bytes", len(response), MPMaxResponseSize)
+	}
+	operation_result := C.mp_management_set_server_response((*C.uint8_t)(&response[0]), C.uint16_t(len(response)))
+	if operation_result != MPResultCodeSuccess {
+		return fmt.Errorf("failed to set the server response uefi variable: %s", getErrorDescription(int(operation_result)))
+	}
+	return nil
+}
+
+// IsMachineRegistered retrieves the machine registration status by reading the UEFI SgxRegistrationStatus.SgxRegistrationComplete variable flag
+func (mp *MPManagement) IsMachineRegistered() (bool, error) {
+	var status C.MpMachineRegistrationStatus
+	operation_result := C.mp_management_get_registration_status(&status)
+	if operation_result != MPResultCodeSuccess {
+		return false, fmt.Errorf("failed to get registration status uefi variable: %s", getErrorDescription(int(operation_result)))
+	}
+	return status == C.MP_MACHINE_REGISTERED, nil
+}
+
+// CompleteMachineRegistrationStatus sets the UEFI SgxRegistrationStatus.SgxRegistrationComplete flag to true
+func (mp *MPManagement) CompleteMachineRegistrationStatus() error {
+	operation_result := C.mp_management_set_registration_status_as_complete()
+	if operation_result != MPResultCodeSuccess {
+		return fmt.Errorf("failed to set the registration status uefi variable : %s", getErrorDescription(int(operation_result)))
+	}
+	return nil
+}
@@ -0,0 +1,168 @@
+//go:build !cgo || nosgx
+
+package mpmanagement
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/opensovereigncloud/cc-intel-platform-registration/pkg/constants"
+)
+
+// Simulated reports whether the UEFI variables are simulated instead of accessed through the MP management library
+const Simulated = true
+
+// Files of the simulation directory standing for the SGX registration UEFI variables
+const (
+	// RegistrationCompleteFile marks the SgxRegistrationStatus.SgxRegistrationComplete flag as set
+	RegistrationCompleteFile = "registration_complete"
+	// PlatformManifestFile holds a pending platform manifest request
+	PlatformManifestFile = "platform_manifest"
+	// AddPackageRequestFile holds a pending AddPackage request
+	AddPackageRequestFile = "add_package_request"
+	// ServerResponseFile receives the Intel RS response to an AddPackage request
+	ServerResponseFile = "server_response"
+)
+
+// MPManagement simulates the SGX registration UEFI variables with the files of the directory set by
+// the CC_IPR_SGX_SIMULATION_DIR environment variable, for development on platforms without SGX
+type MPManagement struct {
+	dir string
+}
+
+// NewMPManagement creates a new instance of MPManagement
+func NewMPManagement() *MPManagement {
+	return &MPManagement{dir: os.Getenv(constants.SgxSimulationDirEnv)}
+}
+
+// Close terminates the MPManagement instance
+func (mp *MPManagement) Close() {}
+
+func (mp *MPManagement) path(name string) (string, error) {
+	if mp.dir == "" {
+		return "", fmt.Errorf("SGX UEFI variables are not available in a nosgx build unless %s is set", constants.SgxSimulationDirEnv)
+	}
+	return filepath.Join(mp.dir, name), nil
+}
+
+func (mp *MPManagement) readRequest(name string) ([]byte, error) {
+	path, err := mp.path(name)
+	if err != nil {
+		return nil, err
+	}
+	request, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if len(request) > MPMaxRequestSize {
+		return nil, fmt.Errorf("invalid request size %d, expected at most %d bytes", len(request), MPMaxRequestSize)
+	}
+	return request, nil
+}
+
+// readPendingRequest reads the pending request of the given type. Like the MP management library, no request is
+// returned once the platform is registered, nor when the pending request has another type.
+func (mp *MPManagement) readPendingRequest(name string, requestType RequestType) ([]byte, error) {
+	registered, err := mp.IsMachineRegistered()
+	if err != nil {
+		return nil, err
+	}
+	pendingRequestType, err := mp.GetPendingRequestType()
+	if err != nil {
+		return nil, err
+	}
+	if registered || pendingRequestType != requestType {
+		return nil, errors.New(getErrorDescription(MPResultNoPendingData))
+	}
+	return mp.readRequest(name)
+}
+
+func (mp *MPManagement) exists(name string) (bool, error) {
+	path, err := mp.path(name)
+	if err != nil {
+		return false, err
+	}
+	_, err = os.Stat(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// GetPlatformManifest retrieves the platform manifest from the simulated UEFI SgxRegistrationServerRequest
+func (mp *MPManagement) GetPlatformManifest() (PlatformManifest, error) {
+	manifest, err := mp.readPendingRequest(PlatformManifestFile, RequestTypeRegistration)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get platform manifest uefi variable: %w", err)
+	}
+	return manifest, nil
+}
+
+// GetPendingRequestType retrieves the type of the request stored in the simulated UEFI SgxRegistrationServerRequest,
+// or RequestTypeNone if there is none. Removing the request file simulates the reboot consuming it.
+func (mp *MPManagement) GetPendingRequestType() (RequestType, error) {
+	for _, pending := range []struct {
+		name        string
+		requestType RequestType
+	}{
+		{AddPackageRequestFile, RequestTypeAddPackage},
+		{PlatformManifestFile, RequestTypeRegistration},
+	} {
+		exists, err := mp.exists(pending.name)
+		if err != nil {
+			return RequestTypeNone, fmt.Errorf("failed to get the pending request type: %w", err)
+		}
+		if exists {
+			return pending.requestType, nil
+		}
+	}
+	return RequestTypeNone, nil
+}
+
+// GetAddPackageRequest retrieves the AddPackage request from the simulated UEFI SgxRegistrationServerRequest
+func (mp *MPManagement) GetAddPackageRequest() (AddPackageRequest, error) {
+	request, err := mp.readPendingRequest(AddPackageRequestFile, RequestTypeAddPackage)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get add package request uefi variable: %w", err)
+	}
+	return request, nil
+}
+
+// SetServerResponse writes the Intel RS response to the simulated UEFI SgxRegistrationServerResponse
+func (mp *MPManagement) SetServerResponse(response []byte) error {
+	if len(response) == 0 || len(response) > MPMaxResponseSize {
+		return fmt.Errorf("invalid server response size %d, expected between 1 and %d bytes", len(response), MPMaxResponseSize)
+	}
+	path, err := mp.path(ServerResponseFile)
+	if err == nil {
+		err = os.WriteFile(path, response, 0o600)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to set the server response uefi variable: %w", err)
+	}
+	return nil
+}
+
+// IsMachineRegistered retrieves the machine registration status from the simulated UEFI SgxRegistrationStatus.SgxRegistrationComplete flag
+func (mp *MPManagement) IsMachineRegistered() (bool, error) {
+	registered, err := mp.exists(RegistrationCompleteFile)
+	if err != nil {
+		return false, fmt.Errorf("failed to get registration status uefi variable: %w", err)
+	}
+	return registered, nil
+}
+
+// CompleteMachineRegistrationStatus sets the simulated UEFI SgxRegistrationStatus.SgxRegistrationComplete flag to true
+func (mp *MPManagement) CompleteMachineRegistrationStatus() error {
+	path, err := mp.path(RegistrationCompleteFile)
+	if err == nil {
+		err = os.WriteFile(path, nil, 0o600)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to set the registration status uefi variable : %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,137 @@
+//go:build !cgo || nosgx
+
+package mpmanagement
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/opensovereigncloud/cc-intel-platform-registration/pkg/constants"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSimulatedMPManagement(t *testing.T) {
+	cases := []struct {
+		msg                 string
+		files               map[string][]byte
+		wantedRegistered    bool
+		wantedRequestType   RequestType
+		wantedManifest      PlatformManifest
+		wantedAddPackageReq AddPackageRequest
+	}{
+		{
+			msg:               "empty simulation directory has no pending request",
+			wantedRequestType: RequestTypeNone,
+		},
+		{
+			msg:               "pending platform manifest",
+			files:             map[string][]byte{PlatformManifestFile: {0x01, 0x02}},
+			wantedRequestType: RequestTypeRegistration,
+			wantedManifest:    PlatformManifest{0x01, 0x02},
+		},
+		{
+			msg:                 "pending AddPackage request",
+			files:               map[string][]byte{AddPackageRequestFile: {0x03}},
+			wantedRequestType:   RequestTypeAddPackage,
+			wantedAddPackageReq: AddPackageRequest{0x03},
+		},
+		{
+			msg:               "registered platform waiting for a reboot",
+			files:             map[string][]byte{RegistrationCompleteFile: nil, PlatformManifestFile: {0x01}},
+			wantedRegistered:  true,
+			wantedRequestType: RequestTypeRegistration,
+		},
+	}
+
+	for _, c := range cases {
+		dir := t.TempDir()
+		for name, content := range c.files {
+			require.NoError(t, os.WriteFile(filepath.Join(dir, name), content, 0o600), c.msg)
+		}
+		t.Setenv(constants.SgxSimulationDirEnv, dir)
+		mp := NewMPManagement()
+
+		registered, err := mp.IsMachineRegistered()
+		assert.NoError(t, err, c.msg)
+		assert.Equal(t, c.wantedRegistered, registered, c.msg)
+
+		requestType, err := mp.GetPendingRequestType()
+		assert.NoError(t, err, c.msg)
+		assert.Equal(t, c.wantedRequestType, requestType, c.msg)
+		if c.wantedManifest != nil {
+			manifest, err := mp.GetPlatformManifest()
+			assert.NoError(t, err, c.msg)
+			assert.Equal(t, c.wantedManifest, manifest, c.msg)
+		}
+		if c.wantedAddPackageReq != nil {
+			request, err := mp.GetAddPackageRequest()
+			assert.NoError(t, err, c.msg)
+			assert.Equal(t, c.wantedAddPackageReq, request, c.msg)
+		}
+	}
+}
+
+func TestSimulatedMPManagementNoPendingData(t *testing.T) {
+	cases := []struct {
+		msg   string
+		files map[string][]byte
+	}{
+		{
+			msg:   "registered platform waiting for a reboot",
+			files: map[string][]byte{RegistrationCompleteFile: nil, PlatformManifestFile: {0x01}, AddPackageRequestFile: {0x03}},
+		},
+		{
+			msg:   "no pending request",
+			files: map[string][]byte{},
+		},
+	}
+
+	for _, c := range cases {
+		dir := t.TempDir()
+		for name, content := range c.files {
+			require.NoError(t, os.WriteFile(filepath.Join(dir, name), content, 0o600), c.msg)
+		}
+		t.Setenv(constants.SgxSimulationDirEnv, dir)
+		mp := NewMPManagement()
+
+		_, err := mp.GetPlatformManifest()
+		assert.ErrorContains(t, err, "No Pending Data", c.msg)
+		_, err = mp.GetAddPackageRequest()
+		assert.ErrorContains(t, err, "No Pending Data", c.msg)
+	}
+
+	// like the UEFI variable, the pending request has a single type
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, AddPackageRequestFile), []byte{0x03}, 0o600))
+	t.Setenv(constants.SgxSimulationDirEnv, dir)
+	_, err := NewMPManagement().GetPlatformManifest()
+	assert.ErrorContains(t, err, "No Pending Data")
+}
+
+func TestSimulatedMPManagementWrites(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv(constants.SgxSimulationDirEnv, dir)
+	mp := NewMPManagement()
+
+	assert.Error(t, mp.SetServerResponse(nil))
+	require.NoError(t, mp.SetServerResponse([]byte{0x04}))
+	response, err := os.ReadFile(filepath.Join(dir, ServerResponseFile))
+	require.NoError(t, err)
+	assert.Equal(t, []byte{0x04}, response)
+
+	require.NoError(t, mp.CompleteMachineRegistrationStatus())
+	registered, err := mp.IsMachineRegistered()
+	assert.NoError(t, err)
+	assert.True(t, registered)
+}
+
+func TestSimulatedMPManagementWithoutDirectory(t *testing.T) {
+	t.Setenv(constants.SgxSimulationDirEnv, "")
+	mp := NewMPManagement()
+
+	_, err := mp.IsMachineRegistered()
+	assert.ErrorContains(t, err, constants.SgxSimulationDirEnv)
+	assert.Error(t, mp.CompleteMachineRegistrationStatus())
+}
@@ -0,0 +1,43 @@
+//go:build !cgo || nosgx
+
+package sgxplatforminfo
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/opensovereigncloud/cc-intel-platform-registration/pkg/constants"
+)
+
+// PceInfoFile is the file of the simulation directory holding the simulated PCE platform information, e.g.
+// {"encrypted_ppid": "<hex>", "pce_id": "0000", "pce_isvsvn": "0x0d"}
+const PceInfoFile = "pce_info.json"
+
+// GetSgxPcePlatformInfo reads the simulated PCE information from the directory set by the
+// CC_IPR_SGX_SIMULATION_DIR environment variable, for development on platforms without SGX
+func GetSgxPcePlatformInfo() (*SgxPcePlatformInfo, error) {
+	dir := os.Getenv(constants.SgxSimulationDirEnv)
+	if dir == "" {
+		return nil, fmt.Errorf("the sgx pce platform info is not available in a nosgx build unless %s is set", constants.SgxSimulationDirEnv)
+	}
+	content, err := os.ReadFile(filepath.Join(dir, PceInfoFile))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get the sgx pce platform info: %w", err)
+	}
+
+	var simulated struct {
+		EncryptedPPID string `json:"encrypted_ppid"`
+		PCEID         string `json:"pce_id"`
+		PCEisvsvn     string `json:"pce_isvsvn"`
+	}
+	if err := json.Unmarshal(content, &simulated); err != nil {
+		return nil, fmt.Errorf("failed to decode the simulated sgx pce platform info: %w", err)
+	}
+
+	info := &SgxPcePlatformInfo{EncryptedPPID: simulated.EncryptedPPID}
+	info.PCEInfo.PCEID = simulated.PCEID
+	info.PCEInfo.PCEisvsvn = simulated.PCEisvsvn
+	return info, nil
+}
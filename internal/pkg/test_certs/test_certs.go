@@ -0,0 +1,76 @@
+// Package testcerts issues the certificates of the test fixtures, e.g. the Intel SGX PCK certificate chains
+package testcerts
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// Certificate is a test certificate along with its private key
+type Certificate struct {
+	Certificate *x509.Certificate
+	Key         *ecdsa.PrivateKey
+}
+
+// PEM returns the PEM encoding of the certificate
+func (c Certificate) PEM() string {
+	return EncodePEM(c)
+}
+
+// New issues a certificate signed by the parent, or a self-signed one when parent is nil. The certificate is
+// valid from an hour before now, or before notAfter for an expired certificate, until notAfter.
+func New(t testing.TB, commonName string, parent *Certificate, isCA bool, notAfter time.Time, extensions ...pkix.Extension) Certificate {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	serialNumber, err := rand.Int(rand.Reader, big.NewInt(1<<62))
+	require.NoError(t, err)
+
+	notBefore := time.Now().Add(-time.Hour)
+	if notAfter.Before(notBefore) {
+		notBefore = notAfter.Add(-time.Hour)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          serialNumber,
+		Subject:               pkix.Name{CommonName: commonName},
+		NotBefore:             notBefore,
+		NotAfter:              notAfter,
+		IsCA:                  isCA,
+		BasicConstraintsValid: true,
+		ExtraExtensions:       extensions,
+	}
+	if isCA {
+		template.KeyUsage = x509.KeyUsageCertSign | x509.KeyUsageCRLSign
+	} else {
+		template.KeyUsage = x509.KeyUsageDigitalSignature
+	}
+
+	issuer, issuerKey := template, key
+	if parent != nil {
+		issuer, issuerKey = parent.Certificate, parent.Key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, issuer, &key.PublicKey, issuerKey)
+	require.NoError(t, err)
+	certificate, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	return Certificate{Certificate: certificate, Key: key}
+}
+
+// EncodePEM returns the concatenated PEM encoding of the certificates, e.g. an issuer chain
+func EncodePEM(certificates ...Certificate) string {
+	var encoded string
+	for _, certificate := range certificates {
+		encoded += string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: certificate.Certificate.Raw}))
+	}
+	return encoded
+}
@@ -8,17 +8,30 @@ import (
 	"os"
 	"os/signal"
 	"strconv"
+	"strings"
 	"syscall"
 	"time"
 
+	mpmanagement "github.com/opensovereigncloud/cc-intel-platform-registration/internal/pkg/mp_management"
+	"github.com/opensovereigncloud/cc-intel-platform-registration/pkg/admin"
 	"github.com/opensovereigncloud/cc-intel-platform-registration/pkg/constants"
+	intelservices "github.com/opensovereigncloud/cc-intel-platform-registration/pkg/intel_services"
 	"github.com/opensovereigncloud/cc-intel-platform-registration/pkg/metrics"
+	nodestatus "github.com/opensovereigncloud/cc-intel-platform-registration/pkg/node_status"
+	"github.com/opensovereigncloud/cc-intel-platform-registration/pkg/oneshot"
+	pckcerts "github.com/opensovereigncloud/cc-intel-platform-registration/pkg/pck_certs"
 	"github.com/opensovereigncloud/cc-intel-platform-registration/pkg/registration"
+	registrationresource "github.com/opensovereigncloud/cc-intel-platform-registration/pkg/registration_resource"
+	statusevents "github.com/opensovereigncloud/cc-intel-platform-registration/pkg/status_events"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/spf13/pflag"
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
 	"golang.org/x/sync/errgroup"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
 )
 
 // Version information
@@ -91,6 +104,183 @@ func GetRegistrationServiceIntervalDuration(logger *zap.Logger) time.Duration {
 	return time.Duration(interval) * time.Minute
 }
 
+// GetSchedulePolicy retrieves the schedule of the registration checks from environment variables
+func GetSchedulePolicy(logger *zap.Logger) (registration.SchedulePolicy, error) {
+	defaultPolicy := registration.DefaultSchedulePolicy()
+	policy := registration.SchedulePolicy{
+		Interval:            GetRegistrationServiceIntervalDuration(logger),
+		FailureInitialDelay: getDurationEnvOrDefault(logger, constants.ScheduleFailureInitialDelayEnv, defaultPolicy.FailureInitialDelay),
+		FailureMaxDelay:     getDurationEnvOrDefault(logger, constants.ScheduleFailureMaxDelayEnv, defaultPolicy.FailureMaxDelay),
+		RegisteredInterval:  getDurationEnvOrDefault(logger, constants.ScheduleRegisteredIntervalEnv, defaultPolicy.RegisteredInterval),
+		RebootPollInterval:  getDurationEnvOrDefault(logger, constants.ScheduleRebootPollIntervalEnv, defaultPolicy.RebootPollInterval),
+	}
+	return policy, policy.Validate()
+}
+
+// GetRegistrationCheckTimeout retrieves the deadline of a registration check from environment variables
+func GetRegistrationCheckTimeout(logger *zap.Logger) time.Duration {
+	checkTimeout := getDurationEnvOrDefault(logger, constants.RegistrationCheckTimeoutEnv, constants.DefaultRegistrationCheckTimeout)
+	if checkTimeout <= 0 {
+		logger.Error("registration check timeout must be positive",
+			zap.String("env_var", constants.RegistrationCheckTimeoutEnv),
+			zap.Duration("default_value", constants.DefaultRegistrationCheckTimeout))
+		return constants.DefaultRegistrationCheckTimeout
+	}
+	return checkTimeout
+}
+
+// GetStateMaxRecords retrieves the number of checks and status transitions kept in the persisted state
+func GetStateMaxRecords(logger *zap.Logger) int {
+	maxRecords := getIntEnvOrDefault(logger, constants.StateMaxRecordsEnv, constants.DefaultStateMaxRecords)
+	if maxRecords <= 0 {
+		logger.Error("the number of persisted records must be positive",
+			zap.String("env_var", constants.StateMaxRecordsEnv),
+			zap.Int("default_value", constants.DefaultStateMaxRecords))
+		return constants.DefaultStateMaxRecords
+	}
+	return maxRecords
+}
+
+// getEnvOrDefault returns the value of the environment variable, or the default value when it is not set
+func getEnvOrDefault(logger *zap.Logger, envVar string, defaultValue string) string {
+	value := os.Getenv(envVar)
+	if value == "" {
+		logger.Info("environment variable not set, using default",
+			zap.String("env_var", envVar),
+			zap.String("default_value", defaultValue))
+		return defaultValue
+	}
+	return value
+}
+
+// getIntEnvOrDefault returns the integer value of the environment variable, or the default value when it is not set or invalid
+func getIntEnvOrDefault(logger *zap.Logger, envVar string, defaultValue int) int {
+	valueStr := os.Getenv(envVar)
+	if valueStr == "" {
+		return defaultValue
+	}
+	value, err := strconv.Atoi(valueStr)
+	if err != nil {
+		logger.Error("failed to parse environment variable",
+			zap.String("env_var", envVar),
+			zap.Error(err),
+			zap.Int("default_value", defaultValue))
+		return defaultValue
+	}
+	return value
+}
+
+// getBoolEnvOrDefault returns the boolean value (e.g. true) of the environment variable, or the default value when it is not set or invalid
+func getBoolEnvOrDefault(logger *zap.Logger, envVar string, defaultValue bool) bool {
+	valueStr := os.Getenv(envVar)
+	if valueStr == "" {
+		return defaultValue
+	}
+	value, err := strconv.ParseBool(valueStr)
+	if err != nil {
+		logger.Error("failed to parse environment variable",
+			zap.String("env_var", envVar),
+			zap.Error(err),
+			zap.Bool("default_value", defaultValue))
+		return defaultValue
+	}
+	return value
+}
+
+// getDurationEnvOrDefault returns the duration value (e.g. 30s) of the environment variable,
+// or the default value when it is not set or invalid
+func getDurationEnvOrDefault(logger *zap.Logger, envVar string, defaultValue time.Duration) time.Duration {
+	valueStr := os.Getenv(envVar)
+	if valueStr == "" {
+		return defaultValue
+	}
+	value, err := time.ParseDuration(valueStr)
+	if err != nil {
+		logger.Error("failed to parse environment variable",
+			zap.String("env_var", envVar),
+			zap.Error(err),
+			zap.Duration("default_value", defaultValue))
+		return defaultValue
+	}
+	return value
+}
+
+// GetIntelRetryPolicy retrieves the retry policy of the Intel requests from environment variables
+func GetIntelRetryPolicy(logger *zap.Logger) intelservices.RetryPolicy {
+	defaultPolicy := intelservices.DefaultRetryPolicy()
+	return intelservices.RetryPolicy{
+		MaxAttempts:    getIntEnvOrDefault(logger, constants.IntelRetryMaxAttemptsEnv, defaultPolicy.MaxAttempts),
+		InitialBackoff: getDurationEnvOrDefault(logger, constants.IntelRetryInitialBackoffEnv, defaultPolicy.InitialBackoff),
+		MaxBackoff:     getDurationEnvOrDefault(logger, constants.IntelRetryMaxBackoffEnv, defaultPolicy.MaxBackoff),
+		MaxElapsedTime: getDurationEnvOrDefault(logger, constants.IntelRetryMaxElapsedTimeEnv, defaultPolicy.MaxElapsedTime),
+	}
+}
+
+// GetIntelProxyConf retrieves the proxy configuration for the outbound Intel traffic from environment variables
+func GetIntelProxyConf(logger *zap.Logger) (intelservices.ProxyConf, error) {
+	proxyType, err := intelservices.ParseProxyType(getEnvOrDefault(logger, constants.IntelProxyTypeEnv, constants.DefaultIntelProxyType))
+	if err != nil {
+		return intelservices.ProxyConf{}, err
+	}
+	return intelservices.ProxyConf{
+		Type:     proxyType,
+		URL:      os.Getenv(constants.IntelProxyURLEnv),
+		Username: os.Getenv(constants.IntelProxyUsernameEnv),
+		Password: os.Getenv(constants.IntelProxyPasswordEnv),
+		NoProxy:  os.Getenv(constants.IntelNoProxyEnv),
+	}, nil
+}
+
+// GetIntelCredentialsConf retrieves the PCS subscription key and the PCCS user token sources from environment variables.
+// The secret values themselves are never logged.
+func GetIntelCredentialsConf() intelservices.CredentialsConf {
+	return intelservices.CredentialsConf{
+		SubscriptionKey: intelservices.SecretSource{
+			Value: os.Getenv(constants.IntelPcsSubscriptionKeyEnv),
+			File:  os.Getenv(constants.IntelPcsSubscriptionKeyFileEnv),
+		},
+		UserToken: intelservices.SecretSource{
+			Value: os.Getenv(constants.PccsUserTokenEnv),
+			File:  os.Getenv(constants.PccsUserTokenFileEnv),
+		},
+	}
+}
+
+// GetIntelServiceConfig retrieves the Intel RS/PCS base URLs, the PCS API version and the proxy configuration
+// from environment variables and validates them
+func GetIntelServiceConfig(logger *zap.Logger) (intelservices.Config, error) {
+	proxyConf, err := GetIntelProxyConf(logger)
+	if err != nil {
+		return intelservices.Config{}, err
+	}
+	config := intelservices.Config{
+		RegistrationServiceBaseURL: getEnvOrDefault(logger, constants.IntelRegistrationServiceBaseURLEnv, constants.DefaultIntelRegistrationServiceBaseURL),
+		PcsBaseURL:                 getEnvOrDefault(logger, constants.IntelPcsBaseURLEnv, constants.DefaultIntelPcsBaseURL),
+		PcsAPIVersion:              getEnvOrDefault(logger, constants.IntelPcsAPIVersionEnv, constants.DefaultIntelPcsAPIVersion),
+		Proxy:                      proxyConf,
+		Retry:                      GetIntelRetryPolicy(logger),
+		Credentials:                GetIntelCredentialsConf(),
+	}
+	if rootCAFile := os.Getenv(constants.IntelSgxRootCAFileEnv); rootCAFile != "" {
+		rootCA, err := pckcerts.LoadRootCA(rootCAFile)
+		if err != nil {
+			return config, fmt.Errorf("failed to load the SGX root CA override: %w", err)
+		}
+		config.SgxRootCA = rootCA
+	}
+	if err := config.Validate(); err != nil {
+		return config, err
+	}
+
+	logger.Info("Intel services configured",
+		zap.String("platformRegistrationEndpoint", config.PlatformRegistrationEndpoint()),
+		zap.String("pckRetrievalEndpoint", config.PckRetrievalEndpoint()),
+		zap.String("pcsAPIVersion", config.PcsAPIVersion),
+		zap.Int("retryMaxAttempts", config.Retry.MaxAttempts),
+		zap.Duration("retryMaxElapsedTime", config.Retry.MaxElapsedTime))
+	return config, nil
+}
+
 // createLogger creates a new zap.Logger with the specified configuration
 func createLogger(level string, encoder string, timeEncoding string) (*zap.Logger, error) {
 	// Set defaults if not specified
@@ -142,19 +332,168 @@ func createLogger(level string, encoder string, timeEncoding string) (*zap.Logge
 	return cfg.Build()
 }
 
-// runService starts the registration service and HTTP server
-func runService(ctx context.Context, logger *zap.Logger) error {
-	// Log application startup information
+// GetServiceConfig retrieves the configuration of the registration service from environment variables;
+// the dry run is enabled by the dryRun flag or the environment
+func GetServiceConfig(logger *zap.Logger, dryRun bool) (registration.ServiceConfig, error) {
+	intelServiceConfig, err := GetIntelServiceConfig(logger)
+	if err != nil {
+		logger.Error("invalid Intel services configuration", zap.Error(err))
+		return registration.ServiceConfig{}, err
+	}
+	schedulePolicy, err := GetSchedulePolicy(logger)
+	if err != nil {
+		logger.Error("invalid registration check schedule", zap.Error(err))
+		return registration.ServiceConfig{}, err
+	}
+	publishers, err := GetPublishers(logger)
+	if err != nil {
+		logger.Error("invalid Kubernetes configuration", zap.Error(err))
+		return registration.ServiceConfig{}, err
+	}
+	stateDir := os.Getenv(constants.StateDirEnv)
+	if stateDir == "" {
+		logger.Info("state directory not set, the registration state is not persisted across restarts",
+			zap.String("env_var", constants.StateDirEnv))
+	}
+	rebootSentinelPath := os.Getenv(constants.RebootSentinelPathEnv)
+	var rebootSentinelReason string
+	if rebootSentinelPath != "" {
+		rebootSentinelReason = getEnvOrDefault(logger, constants.RebootSentinelReasonEnv, constants.DefaultRebootSentinelReason)
+		logger.Info("reboot sentinel enabled", zap.String("path", rebootSentinelPath), zap.String("reason", rebootSentinelReason))
+	}
+	return registration.ServiceConfig{
+		Schedule:             schedulePolicy,
+		CheckTimeout:         GetRegistrationCheckTimeout(logger),
+		IntelServiceConfig:   intelServiceConfig,
+		StateDir:             stateDir,
+		StateMaxRecords:      GetStateMaxRecords(logger),
+		DryRun:               dryRun || getBoolEnvOrDefault(logger, constants.DryRunEnv, false),
+		RebootSentinelPath:   rebootSentinelPath,
+		RebootSentinelReason: rebootSentinelReason,
+		Publishers:           publishers,
+	}, nil
+}
+
+// GetKubernetesConfig returns the configuration of the clients of the Kubernetes API server the pod runs in
+func GetKubernetesConfig() (*rest.Config, error) {
+	restConfig, err := rest.InClusterConfig()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load the in-cluster configuration: %w", err)
+	}
+	restConfig.UserAgent = appName + "/" + version
+	return restConfig, nil
+}
+
+// GetPublishers creates the publishers of the check results configured through environment variables
+func GetPublishers(logger *zap.Logger) ([]registration.CheckPublisher, error) {
+	nodeName := os.Getenv(constants.NodeNameEnv)
+	if nodeName == "" {
+		logger.Info("node name not set, the registration status is not published on the Kubernetes node",
+			zap.String("env_var", constants.NodeNameEnv))
+		return nil, nil
+	}
+	restConfig, err := GetKubernetesConfig()
+	if err != nil {
+		return nil, err
+	}
+	client, err := kubernetes.NewForConfig(restConfig)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create the Kubernetes client: %w", err)
+	}
+
+	var publishers []registration.CheckPublisher
+	if getBoolEnvOrDefault(logger, constants.NodeLabelsEnabledEnv, true) {
+		logger.Info("registration status published on the Kubernetes node labels", zap.String("node", nodeName))
+		publishers = append(publishers, nodestatus.NewPublisher(client, nodeName))
+	}
+	if getBoolEnvOrDefault(logger, constants.EventsEnabledEnv, true) {
+		eventObjects := []*corev1.ObjectReference{statusevents.NodeReference(nodeName)}
+		podNamespace, podName := os.Getenv(constants.PodNamespaceEnv), os.Getenv(constants.PodNameEnv)
+		if podNamespace != "" && podName != "" {
+			eventObjects = append(eventObjects, statusevents.PodReference(podNamespace, podName, os.Getenv(constants.PodUIDEnv)))
+		}
+		logger.Info("registration status transitions published as Kubernetes events",
+			zap.String("node", nodeName), zap.String("podNamespace", podNamespace), zap.String("pod", podName))
+		publishers = append(publishers, statusevents.NewPublisher(client, nodeName, eventObjects...))
+	}
+	if getBoolEnvOrDefault(logger, constants.CustomResourceEnabledEnv, false) {
+		dynamicClient, err := dynamic.NewForConfig(restConfig)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create the Kubernetes dynamic client: %w", err)
+		}
+		logger.Info("registration status published on the custom resource of the node",
+			zap.String("resource", registrationresource.GroupVersionResource.String()), zap.String("name", nodeName))
+		publishers = append(publishers, registrationresource.NewPublisher(dynamicClient, nodeName))
+	}
+	return publishers, nil
+}
+
+// shutdownPublishers stops the publishers working in the background, e.g. recording the events
+func shutdownPublishers(publishers []registration.CheckPublisher) {
+	for _, publisher := range publishers {
+		if p, ok := publisher.(interface{ Shutdown() }); ok {
+			p.Shutdown()
+		}
+	}
+}
+
+// logStartup logs the application startup information
+func logStartup(logger *zap.Logger) {
 	logger.Info("Application starting",
 		zap.String("app", appName),
 		zap.String("version", version),
 		zap.String("buildDate", buildDate))
+	if mpmanagement.Simulated {
+		logger.Warn("built without SGX support, the UEFI variables and the PCE info are simulated",
+			zap.String("env", constants.SgxSimulationDirEnv),
+			zap.String("dir", os.Getenv(constants.SgxSimulationDirEnv)))
+	}
+}
+
+// runCheck runs a single registration check without the HTTP server, prints its summary on the standard output
+// and returns the exit code of its status
+func runCheck(ctx context.Context, logger *zap.Logger, format oneshot.Format, dryRun bool) int {
+	logStartup(logger)
+
+	signalCtx, signalCancel := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer signalCancel()
+
+	serviceConfig, err := GetServiceConfig(logger, dryRun)
+	if err != nil {
+		return 1
+	}
+	defer shutdownPublishers(serviceConfig.Publishers)
+	registrationService := registration.NewRegistrationService(logger, serviceConfig)
+
+	exitCode, err := oneshot.Run(signalCtx, registrationService, os.Stdout, format)
+	if err != nil {
+		logger.Error("unable to print the registration check summary", zap.Error(err))
+	}
+	return exitCode
+}
+
+// runService starts the registration service and HTTP server
+func runService(ctx context.Context, logger *zap.Logger, dryRun bool) error {
+	// Log application startup information
+	logStartup(logger)
 
 	signalCtx, signalCancel := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
 	defer signalCancel()
 
-	intervalDuration := GetRegistrationServiceIntervalDuration(logger)
-	registrationService := registration.NewRegistrationService(logger, intervalDuration)
+	serviceConfig, err := GetServiceConfig(logger, dryRun)
+	if err != nil {
+		return err
+	}
+	defer shutdownPublishers(serviceConfig.Publishers)
+	adminToken := intelservices.SecretSource{
+		Value: os.Getenv(constants.AdminTokenEnv),
+		File:  os.Getenv(constants.AdminTokenFileEnv),
+	}
+	if err := adminToken.Validate(); err != nil {
+		logger.Error("invalid admin token configuration", zap.Error(err))
+		return fmt.Errorf("invalid admin token: %w", err)
+	}
+	registrationService := registration.NewRegistrationService(logger, serviceConfig)
 
 	// Create a context with cancel function for shutdown
 	g, gCtx := errgroup.WithContext(signalCtx)
@@ -185,6 +524,14 @@ func runService(ctx context.Context, logger *zap.Logger) error {
 		w.WriteHeader(http.StatusOK)
 		fmt.Fprintf(w, "Service is ready")
 	})
+	if adminToken.Value != "" || adminToken.File != "" {
+		logger.Info("admin endpoint enabled", zap.String("path", admin.CheckPath), zap.Stringer("token", adminToken))
+		mux.Handle(admin.CheckPath, admin.NewCheckHandler(logger, registrationService,
+			intelservices.NewSecret(logger, "adminToken", adminToken)))
+	} else {
+		logger.Info("admin token not set, the admin endpoint is disabled",
+			zap.String("env_var", constants.AdminTokenEnv), zap.String("file_env_var", constants.AdminTokenFileEnv))
+	}
 
 	// Create server with timeout configuration
 	server := &http.Server{
@@ -196,7 +543,11 @@ func runService(ctx context.Context, logger *zap.Logger) error {
 	g.Go(func() error {
 		logger.Info("Starting HTTP server",
 			zap.String("address", server.Addr),
-			zap.Int("intervalMinutes", int(intervalDuration.Minutes())))
+			zap.Int("intervalMinutes", int(serviceConfig.Schedule.Interval.Minutes())),
+			zap.Duration("failureInitialDelay", serviceConfig.Schedule.FailureInitialDelay),
+			zap.Duration("failureMaxDelay", serviceConfig.Schedule.FailureMaxDelay),
+			zap.Duration("registeredInterval", serviceConfig.Schedule.RegisteredInterval),
+			zap.Duration("rebootPollInterval", serviceConfig.Schedule.RebootPollInterval))
 
 		err := server.ListenAndServe()
 		if err != nil && !errors.Is(err, http.ErrServerClosed) {
@@ -225,7 +576,7 @@ func runService(ctx context.Context, logger *zap.Logger) error {
 	})
 
 	// Wait for all goroutines to complete
-	err := g.Wait()
+	err = g.Wait()
 	if err != nil && !errors.Is(err, context.Canceled) {
 		logger.Error("service error", zap.Error(err))
 		return err
@@ -241,6 +592,10 @@ func main() {
 	encoder := pflag.String("zap-encoder", "json", "Log encoder (json, console)")
 	timeEncoding := pflag.String("zap-time-encoding", "rfc3339nano", "Time encoding (rfc3339, rfc3339nano, iso8601, millis, nanos)")
 
+	once := pflag.Bool("once", false, "Run a single registration check, print its summary and exit with a code derived from its status (same as the check command)")
+	dryRun := pflag.Bool("dry-run", false, "Run the read paths only, listing the UEFI writes and the Intel registration requests instead of performing them (same as CC_IPR_DRY_RUN=true)")
+	output := pflag.StringP("output", "o", string(oneshot.FormatText), "Output format of the single check summary (text, json)")
+
 	// Add help flag
 	help := pflag.BoolP("help", "h", false, "Display help information")
 
@@ -249,11 +604,24 @@ func main() {
 
 	// Display help if requested
 	if *help {
-		fmt.Printf("Usage of %s:\n", appName)
+		fmt.Printf("Usage of %s [check]:\n", appName)
 		pflag.PrintDefaults()
 		os.Exit(0)
 	}
 
+	switch {
+	case pflag.NArg() == 1 && pflag.Arg(0) == "check":
+		*once = true
+	case pflag.NArg() > 0:
+		fmt.Fprintf(os.Stderr, "unknown command %q, the only command is check\n", strings.Join(pflag.Args(), " "))
+		os.Exit(1)
+	}
+	outputFormat, err := oneshot.ParseFormat(*output)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
 	// Setup panic handler
 	defer func() {
 		if r := recover(); r != nil {
@@ -276,8 +644,13 @@ func main() {
 	// Create context
 	ctx := context.Background()
 
+	// Run a single check
+	if *once {
+		os.Exit(runCheck(ctx, logger, outputFormat, *dryRun))
+	}
+
 	// Run the service
-	err = runService(ctx, logger)
+	err = runService(ctx, logger, *dryRun)
 
 	if err != nil {
 		os.Exit(1)
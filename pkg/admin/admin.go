@@ -0,0 +1,74 @@
+package admin
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	intelservices "github.com/opensovereigncloud/cc-intel-platform-registration/pkg/intel_services"
+	"github.com/opensovereigncloud/cc-intel-platform-registration/pkg/registration"
+	"go.uber.org/zap"
+)
+
+// CheckPath is the path of the admin endpoint triggering a registration check
+const CheckPath = "/admin/check"
+
+// CheckTrigger runs an immediate registration check, as implemented by registration.RegistrationService
+type CheckTrigger interface {
+	TriggerCheck(ctx context.Context) (registration.CheckResult, error)
+}
+
+// ErrorResponse is the JSON body returned when the check endpoint fails
+type ErrorResponse struct {
+	Error string `json:"error"`
+}
+
+// NewCheckHandler returns the handler of the check endpoint: an authenticated POST runs an immediate
+// registration check, or joins the one in progress, and answers with its result. The requests must carry
+// the admin token as a bearer token; the token is read on every request, so it can be rotated.
+func NewCheckHandler(logger *zap.Logger, trigger CheckTrigger, token *intelservices.Secret) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			writeJSON(logger, w, http.StatusMethodNotAllowed, ErrorResponse{Error: "method not allowed"})
+			return
+		}
+		if !authorized(r, token.Get()) {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="admin"`)
+			writeJSON(logger, w, http.StatusUnauthorized, ErrorResponse{Error: "unauthorized"})
+			return
+		}
+
+		logger.Info("Registration check triggered", zap.String("remoteAddr", r.RemoteAddr))
+		checkResult, err := trigger.TriggerCheck(r.Context())
+		switch {
+		case errors.Is(err, registration.ErrServiceNotRunning):
+			writeJSON(logger, w, http.StatusServiceUnavailable, ErrorResponse{Error: err.Error()})
+		case err != nil:
+			// the client went away, the check goes on and is published as usual
+			logger.Warn("triggered registration check abandoned by the client", zap.Error(err))
+		default:
+			writeJSON(logger, w, http.StatusOK, registration.NewCheckSummary(checkResult))
+		}
+	})
+}
+
+// authorized checks the bearer token of the request in constant time; no request is authorized without a token
+func authorized(r *http.Request, token string) bool {
+	bearerToken, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if token == "" || !ok {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(bearerToken), []byte(token)) == 1
+}
+
+func writeJSON(logger *zap.Logger, w http.ResponseWriter, statusCode int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	if err := json.NewEncoder(w).Encode(body); err != nil {
+		logger.Error("unable to write the admin response", zap.Error(err))
+	}
+}
@@ -0,0 +1,148 @@
+package admin
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	intelservices "github.com/opensovereigncloud/cc-intel-platform-registration/pkg/intel_services"
+	"github.com/opensovereigncloud/cc-intel-platform-registration/pkg/metrics"
+	"github.com/opensovereigncloud/cc-intel-platform-registration/pkg/registration"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+type fakeCheckTrigger struct {
+	checkResult registration.CheckResult
+	err         error
+	calls       int
+}
+
+func (f *fakeCheckTrigger) TriggerCheck(_ context.Context) (registration.CheckResult, error) {
+	f.calls++
+	return f.checkResult, f.err
+}
+
+func TestCheckHandler(t *testing.T) {
+	completedAt := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	cases := []struct {
+		msg                string
+		method             string
+		authorization      string
+		token              string
+		trigger            *fakeCheckTrigger
+		wantedStatusCode   int
+		wantedTriggerCalls int
+		wantedBody         any
+	}{
+		{
+			msg:           "authorized POST returns the check result",
+			method:        http.MethodPost,
+			authorization: "Bearer admin-token",
+			token:         "admin-token",
+			trigger: &fakeCheckTrigger{checkResult: registration.CheckResult{
+				StatusCodeMetric:        metrics.StatusCodeMetric{Status: metrics.PlatformRebootNeeded},
+				RegistrationCompletedAt: completedAt,
+				IntelResponses:          []*intelservices.IntelResponse{{RequestID: "request-id"}},
+			}},
+			wantedStatusCode:   http.StatusOK,
+			wantedTriggerCalls: 1,
+			wantedBody: &registration.CheckSummary{
+				Status:                  5,
+				Description:             metrics.PlatformRebootNeeded.String(),
+				IntelRequestIDs:         []string{"request-id"},
+				RegistrationCompletedAt: &completedAt,
+			},
+		},
+		{
+			msg:           "Intel failure is part of the check result",
+			method:        http.MethodPost,
+			authorization: "Bearer admin-token",
+			token:         "admin-token",
+			trigger: &fakeCheckTrigger{checkResult: registration.CheckResult{
+				StatusCodeMetric: metrics.StatusCodeMetric{Status: metrics.InvalidRegistrationRequest, HttpStatusCode: "400", IntelError: "InvalidRequestSyntax"},
+			}},
+			wantedStatusCode:   http.StatusOK,
+			wantedTriggerCalls: 1,
+			wantedBody: &registration.CheckSummary{
+				Status:         11,
+				Description:    metrics.InvalidRegistrationRequest.String(),
+				HttpStatusCode: "400",
+				IntelErrorCode: "InvalidRequestSyntax",
+			},
+		},
+		{
+			msg:                "service not running",
+			method:             http.MethodPost,
+			authorization:      "Bearer admin-token",
+			token:              "admin-token",
+			trigger:            &fakeCheckTrigger{err: registration.ErrServiceNotRunning},
+			wantedStatusCode:   http.StatusServiceUnavailable,
+			wantedTriggerCalls: 1,
+			wantedBody:         &ErrorResponse{Error: registration.ErrServiceNotRunning.Error()},
+		},
+		{
+			msg:              "GET is not allowed",
+			method:           http.MethodGet,
+			authorization:    "Bearer admin-token",
+			token:            "admin-token",
+			trigger:          &fakeCheckTrigger{},
+			wantedStatusCode: http.StatusMethodNotAllowed,
+			wantedBody:       &ErrorResponse{Error: "method not allowed"},
+		},
+		{
+			msg:              "missing token is unauthorized",
+			method:           http.MethodPost,
+			token:            "admin-token",
+			trigger:          &fakeCheckTrigger{},
+			wantedStatusCode: http.StatusUnauthorized,
+			wantedBody:       &ErrorResponse{Error: "unauthorized"},
+		},
+		{
+			msg:              "wrong token is unauthorized",
+			method:           http.MethodPost,
+			authorization:    "Bearer other-token",
+			token:            "admin-token",
+			trigger:          &fakeCheckTrigger{},
+			wantedStatusCode: http.StatusUnauthorized,
+			wantedBody:       &ErrorResponse{Error: "unauthorized"},
+		},
+		{
+			msg:              "no request is authorized without a configured token",
+			method:           http.MethodPost,
+			authorization:    "Bearer ",
+			trigger:          &fakeCheckTrigger{},
+			wantedStatusCode: http.StatusUnauthorized,
+			wantedBody:       &ErrorResponse{Error: "unauthorized"},
+		},
+	}
+
+	for _, c := range cases {
+		token := intelservices.NewSecret(zap.NewNop(), "adminToken", intelservices.SecretSource{Value: c.token})
+		handler := NewCheckHandler(zap.NewNop(), c.trigger, token)
+		req := httptest.NewRequest(c.method, CheckPath, nil)
+		if c.authorization != "" {
+			req.Header.Set("Authorization", c.authorization)
+		}
+		recorder := httptest.NewRecorder()
+
+		handler.ServeHTTP(recorder, req)
+
+		assert.Equal(t, c.wantedStatusCode, recorder.Code, c.msg)
+		assert.Equal(t, "application/json", recorder.Header().Get("Content-Type"), c.msg)
+		assert.Equal(t, c.wantedTriggerCalls, c.trigger.calls, c.msg)
+		switch wantedBody := c.wantedBody.(type) {
+		case *registration.CheckSummary:
+			body := &registration.CheckSummary{}
+			assert.NoError(t, json.Unmarshal(recorder.Body.Bytes(), body), c.msg)
+			assert.Equal(t, wantedBody, body, c.msg)
+		case *ErrorResponse:
+			body := &ErrorResponse{}
+			assert.NoError(t, json.Unmarshal(recorder.Body.Bytes(), body), c.msg)
+			assert.Equal(t, wantedBody, body, c.msg)
+		}
+	}
+}
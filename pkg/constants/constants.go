@@ -5,9 +5,105 @@ import "time"
 const DefaultRegistrationServiceIntervalInMinutes = 60
 const DefaultRegistrationServiceIntervalInMinutesEnv = "CC_IPR_REGISTRATION_INTERVAL_MINUTES"
 
+// DefaultRegistrationCheckTimeout bounds a registration check, including the retries of all its Intel requests
+const DefaultRegistrationCheckTimeout = 15 * time.Minute
+const RegistrationCheckTimeoutEnv = "CC_IPR_REGISTRATION_CHECK_TIMEOUT"
+
+// Delays of the check schedule: failures (10, 12, 99) back off exponentially from the initial delay up to the
+// max delay, a registered platform (09) is confirmed at the registered interval and a pending reboot
+// (05, 06, 07) is polled at the reboot poll interval; the other statuses use the registration interval
+const DefaultScheduleFailureInitialDelay = time.Minute
+const ScheduleFailureInitialDelayEnv = "CC_IPR_SCHEDULE_FAILURE_INITIAL_DELAY"
+const DefaultScheduleFailureMaxDelay = time.Hour
+const ScheduleFailureMaxDelayEnv = "CC_IPR_SCHEDULE_FAILURE_MAX_DELAY"
+const DefaultScheduleRegisteredInterval = 6 * time.Hour
+const ScheduleRegisteredIntervalEnv = "CC_IPR_SCHEDULE_REGISTERED_INTERVAL"
+const DefaultScheduleRebootPollInterval = 5 * time.Minute
+const ScheduleRebootPollIntervalEnv = "CC_IPR_SCHEDULE_REBOOT_POLL_INTERVAL"
+
+// StateDirEnv is the directory the registration state is persisted in across restarts; not persisted when unset
+const StateDirEnv = "CC_IPR_STATE_DIR"
+
+// DefaultStateMaxRecords bounds the number of checks and status transitions kept in the persisted state
+const DefaultStateMaxRecords = 100
+const StateMaxRecordsEnv = "CC_IPR_STATE_MAX_RECORDS"
+
 const DefaultRegistrationServicePort = 8080
 const RegistrationServicePortEnv = "CC_IPR_REGISTRATION_SERVICE_PORT"
 
-const IntelPlatformRegistrationEndpoint = "https://api.trustedservices.intel.com/sgx/registration/v1/platform"
-const IntelPckRetrievalEndpoint = "https://api.trustedservices.intel.com/sgx/certification/v4/pckcerts"
+const DefaultIntelRegistrationServiceBaseURL = "https://api.trustedservices.intel.com/sgx/registration"
+const IntelRegistrationServiceBaseURLEnv = "CC_IPR_INTEL_RS_BASE_URL"
+
+const DefaultIntelPcsBaseURL = "https://api.trustedservices.intel.com/sgx/certification"
+const IntelPcsBaseURLEnv = "CC_IPR_INTEL_PCS_BASE_URL"
+
+const DefaultIntelPcsAPIVersion = "v4"
+const IntelPcsAPIVersionEnv = "CC_IPR_INTEL_PCS_API_VERSION"
+
+const DefaultIntelProxyType = "default"
+const IntelProxyTypeEnv = "CC_IPR_PROXY_TYPE"
+const IntelProxyURLEnv = "CC_IPR_PROXY_URL"
+const IntelProxyUsernameEnv = "CC_IPR_PROXY_USERNAME"
+const IntelProxyPasswordEnv = "CC_IPR_PROXY_PASSWORD"
+const IntelNoProxyEnv = "CC_IPR_PROXY_NO_PROXY"
+
+const IntelRetryMaxAttemptsEnv = "CC_IPR_INTEL_RETRY_MAX_ATTEMPTS"
+const IntelRetryInitialBackoffEnv = "CC_IPR_INTEL_RETRY_INITIAL_BACKOFF"
+const IntelRetryMaxBackoffEnv = "CC_IPR_INTEL_RETRY_MAX_BACKOFF"
+const IntelRetryMaxElapsedTimeEnv = "CC_IPR_INTEL_RETRY_MAX_ELAPSED_TIME"
+
+const IntelSgxRootCAFileEnv = "CC_IPR_INTEL_SGX_ROOT_CA_FILE"
+
+const IntelPcsSubscriptionKeyEnv = "CC_IPR_INTEL_PCS_SUBSCRIPTION_KEY"
+const IntelPcsSubscriptionKeyFileEnv = "CC_IPR_INTEL_PCS_SUBSCRIPTION_KEY_FILE"
+const PccsUserTokenEnv = "CC_IPR_PCCS_USER_TOKEN"
+const PccsUserTokenFileEnv = "CC_IPR_PCCS_USER_TOKEN_FILE"
+
+// Bearer token of the admin endpoints; the admin endpoints are disabled when no token is set
+const AdminTokenEnv = "CC_IPR_ADMIN_TOKEN"
+const AdminTokenFileEnv = "CC_IPR_ADMIN_TOKEN_FILE"
+
 const IntelRequestTimeout = 2 * time.Minute
+
+// PublishTimeout bounds the publication of a check result, e.g. the patch of the Kubernetes node
+const PublishTimeout = 30 * time.Second
+
+// EventsFlushTimeout bounds the wait for the queued Kubernetes events to be sent at shutdown
+const EventsFlushTimeout = 5 * time.Second
+
+// PckCrlRefreshInterval bounds how long a PCK CRL is reused before being downloaded again, even if its nextUpdate is later
+const PckCrlRefreshInterval = 24 * time.Hour
+
+// DryRunEnv enables the dry run: the UEFI writes and the Intel registration requests are listed instead of performed
+const DryRunEnv = "CC_IPR_DRY_RUN"
+
+// RebootSentinelPathEnv is the file written while the platform waits for a reboot, following the
+// /var/run/reboot-required convention watched by kured; no file is written when unset
+const RebootSentinelPathEnv = "CC_IPR_REBOOT_SENTINEL_PATH"
+const DefaultRebootSentinelReason = "SGX platform registration completed, a reboot is required"
+const RebootSentinelReasonEnv = "CC_IPR_REBOOT_SENTINEL_REASON"
+
+// NodeNameEnv is the name of the Kubernetes node running the agent, whose labels, annotations, events and
+// SgxPlatformRegistration reflect the registration status; nothing is published on Kubernetes when unset
+const NodeNameEnv = "CC_IPR_NODE_NAME"
+
+// NodeLabelsEnabledEnv enables the labels and annotations of the node reflecting the registration status
+const NodeLabelsEnabledEnv = "CC_IPR_NODE_LABELS_ENABLED"
+
+// CustomResourceEnabledEnv enables the SgxPlatformRegistration custom resource of the node holding the registration
+// status; the custom resource definition must be installed
+const CustomResourceEnabledEnv = "CC_IPR_CUSTOM_RESOURCE_ENABLED"
+
+// EventsEnabledEnv enables the Kubernetes events emitted on the node and the pod when the registration status changes
+const EventsEnabledEnv = "CC_IPR_EVENTS_ENABLED"
+
+// Namespace, name and UID of the pod running the agent, set through the downward API; the events are also
+// emitted on the pod when the namespace and the name are set
+const (
+	PodNamespaceEnv = "CC_IPR_POD_NAMESPACE"
+	PodNameEnv      = "CC_IPR_POD_NAME"
+	PodUIDEnv       = "CC_IPR_POD_UID"
+)
+
+// SgxSimulationDirEnv is the directory holding the simulated UEFI variables and PCE info of a nosgx build
+const SgxSimulationDirEnv = "CC_IPR_SGX_SIMULATION_DIR"
@@ -0,0 +1,127 @@
+package intelservices
+
+import (
+	"crypto/x509"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/opensovereigncloud/cc-intel-platform-registration/pkg/constants"
+)
+
+const (
+	platformRegistrationPath = "/v1/platform"
+	addPackagePath           = "/v1/package"
+	pckRetrievalPath         = "/pckcerts"
+	tcbInfoPath              = "/tcb"
+	pckCrlPath               = "/pckcrl"
+)
+
+// SupportedPcsAPIVersions lists the PCS API version path segments the IntelService understands
+var SupportedPcsAPIVersions = []string{"v3", "v4"}
+
+// Config holds the Intel Registration Service (RS) and Provisioning Certification Service (PCS)
+// locations used by the IntelService. Both base URLs can point to Intel production, Intel's sandbox,
+// a corporate PCCS or a local stand-in.
+type Config struct {
+	// RegistrationServiceBaseURL is the RS base URL, e.g. https://api.trustedservices.intel.com/sgx/registration
+	RegistrationServiceBaseURL string
+	// PcsBaseURL is the PCS base URL, e.g. https://api.trustedservices.intel.com/sgx/certification
+	PcsBaseURL string
+	// PcsAPIVersion is the PCS API version path segment (v3 or v4)
+	PcsAPIVersion string
+	// Proxy selects how the outbound traffic to the Intel services is routed
+	Proxy ProxyConf
+	// Retry bounds the retries of the requests failing with a transient error
+	Retry RetryPolicy
+	// Credentials are the PCS subscription key and the PCCS user token, when required by the services
+	Credentials CredentialsConf
+	// SgxRootCA is the root CA the PCK certificates are verified against; the Intel SGX Root CA is used when nil
+	SgxRootCA *x509.Certificate
+}
+
+// DefaultConfig returns the configuration targeting the Intel production services
+func DefaultConfig() Config {
+	return Config{
+		RegistrationServiceBaseURL: constants.DefaultIntelRegistrationServiceBaseURL,
+		PcsBaseURL:                 constants.DefaultIntelPcsBaseURL,
+		PcsAPIVersion:              constants.DefaultIntelPcsAPIVersion,
+		Proxy:                      ProxyConf{Type: ProxyTypeDefault},
+		Retry:                      DefaultRetryPolicy(),
+	}
+}
+
+// Validate checks that both base URLs are absolute http(s) URLs, that the PCS API version is supported
+// and that the proxy configuration, retry policy and credentials are consistent
+func (c Config) Validate() error {
+	if err := validateBaseURL(c.RegistrationServiceBaseURL); err != nil {
+		return fmt.Errorf("invalid Intel RS base URL: %w", err)
+	}
+	if err := validateBaseURL(c.PcsBaseURL); err != nil {
+		return fmt.Errorf("invalid Intel PCS base URL: %w", err)
+	}
+	if err := c.Proxy.Validate(); err != nil {
+		return fmt.Errorf("invalid proxy configuration: %w", err)
+	}
+	if err := c.Retry.Validate(); err != nil {
+		return fmt.Errorf("invalid retry policy: %w", err)
+	}
+	if err := c.Credentials.Validate(); err != nil {
+		return fmt.Errorf("invalid credentials: %w", err)
+	}
+	for _, version := range SupportedPcsAPIVersions {
+		if c.PcsAPIVersion == version {
+			return nil
+		}
+	}
+	return fmt.Errorf("unsupported Intel PCS API version %q, expected one of %v", c.PcsAPIVersion, SupportedPcsAPIVersions)
+}
+
+// PlatformRegistrationEndpoint returns the RS endpoint used to register a platform manifest
+func (c Config) PlatformRegistrationEndpoint() string {
+	return strings.TrimRight(c.RegistrationServiceBaseURL, "/") + platformRegistrationPath
+}
+
+// AddPackageEndpoint returns the RS endpoint used to register a CPU package added to or replaced in a platform
+func (c Config) AddPackageEndpoint() string {
+	return strings.TrimRight(c.RegistrationServiceBaseURL, "/") + addPackagePath
+}
+
+// PckRetrievalEndpoint returns the PCS endpoint used to retrieve the PCK certificates of a platform
+func (c Config) PckRetrievalEndpoint() string {
+	return c.pcsEndpoint(pckRetrievalPath)
+}
+
+// TcbInfoEndpoint returns the PCS endpoint used to retrieve the TCB Info of an FMSPC
+func (c Config) TcbInfoEndpoint() string {
+	return c.pcsEndpoint(tcbInfoPath)
+}
+
+// PckCrlEndpoint returns the PCS endpoint used to retrieve the CRL of a PCK CA
+func (c Config) PckCrlEndpoint() string {
+	return c.pcsEndpoint(pckCrlPath)
+}
+
+func (c Config) pcsEndpoint(path string) string {
+	return strings.TrimRight(c.PcsBaseURL, "/") + "/" + c.PcsAPIVersion + path
+}
+
+func validateBaseURL(rawURL string) error {
+	if rawURL == "" {
+		return fmt.Errorf("URL is empty")
+	}
+	parsedURL, err := url.Parse(rawURL)
+	if err != nil {
+		return err
+	}
+	if parsedURL.Scheme != "https" && parsedURL.Scheme != "http" {
+		return fmt.Errorf("URL %q must use the http or https scheme", rawURL)
+	}
+	if parsedURL.Host == "" {
+		return fmt.Errorf("URL %q has no host", rawURL)
+	}
+	if parsedURL.RawQuery != "" || parsedURL.Fragment != "" {
+		return fmt.Errorf("URL %q must not contain a query or fragment", rawURL)
+	}
+	return nil
+}
@@ -0,0 +1,162 @@
+package intelservices
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// Credential headers
+const (
+	// SubscriptionKeyHeader carries the Intel PCS API subscription key
+	SubscriptionKeyHeader = "Ocp-Apim-Subscription-Key"
+	// UserTokenHeader carries the user token of a PCCS
+	UserTokenHeader = "user-token"
+)
+
+const redactedSecret = "[REDACTED]"
+
+// SecretSource is where a secret is loaded from: either an inline value (e.g. an environment variable)
+// or a file, typically a mounted Kubernetes secret, which is reloaded whenever it changes
+type SecretSource struct {
+	Value string
+	File  string
+}
+
+// String never reveals the secret value, so a SecretSource can be logged safely
+func (s SecretSource) String() string {
+	switch {
+	case s.File != "":
+		return "file:" + s.File
+	case s.Value != "":
+		return redactedSecret
+	default:
+		return ""
+	}
+}
+
+// GoString never reveals the secret value either
+func (s SecretSource) GoString() string {
+	return s.String()
+}
+
+// Validate checks that at most one origin of the secret is set
+func (s SecretSource) Validate() error {
+	if s.Value != "" && s.File != "" {
+		return fmt.Errorf("the value and the file are mutually exclusive")
+	}
+	return nil
+}
+
+// CredentialsConf holds the credentials sent to the Intel services or to a PCCS
+type CredentialsConf struct {
+	// SubscriptionKey is the Intel PCS API subscription key, sent to the PCS endpoints that require it
+	SubscriptionKey SecretSource
+	// UserToken is the PCCS user token, sent to the PCCS endpoints that require it
+	UserToken SecretSource
+}
+
+// Validate checks the origin of every credential
+func (c CredentialsConf) Validate() error {
+	if err := c.SubscriptionKey.Validate(); err != nil {
+		return fmt.Errorf("invalid subscription key: %w", err)
+	}
+	if err := c.UserToken.Validate(); err != nil {
+		return fmt.Errorf("invalid user token: %w", err)
+	}
+	return nil
+}
+
+// Secret is the loaded value of a SecretSource. File-backed secrets are reloaded when the modification time
+// or the size of the file changes; the last loaded value is kept when the file cannot be read.
+type Secret struct {
+	log    *zap.Logger
+	name   string
+	source SecretSource
+
+	mutex   sync.Mutex
+	value   string
+	modTime time.Time
+	size    int64
+	loaded  bool
+}
+
+// NewSecret loads the secret of the given source; name identifies it in the logs
+func NewSecret(logger *zap.Logger, name string, source SecretSource) *Secret {
+	return &Secret{log: logger, name: name, source: source, value: strings.TrimSpace(source.Value)}
+}
+
+// Get returns the current value of the secret, or an empty string when it is not configured or not loaded yet
+func (s *Secret) Get() string {
+	if s.source.File == "" {
+		return s.value
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	info, err := os.Stat(s.source.File)
+	if err != nil {
+		s.log.Warn("unable to stat the credential file, keeping the last loaded value",
+			zap.String("credential", s.name), zap.String("file", s.source.File), zap.Error(err))
+		return s.value
+	}
+	if s.loaded && info.ModTime().Equal(s.modTime) && info.Size() == s.size {
+		return s.value
+	}
+
+	content, err := os.ReadFile(s.source.File)
+	if err != nil {
+		s.log.Warn("unable to read the credential file, keeping the last loaded value",
+			zap.String("credential", s.name), zap.String("file", s.source.File), zap.Error(err))
+		return s.value
+	}
+	s.value = strings.TrimSpace(string(content))
+	s.modTime = info.ModTime()
+	s.size = info.Size()
+	if s.loaded {
+		s.log.Info("credential reloaded", zap.String("credential", s.name), zap.String("file", s.source.File))
+	}
+	s.loaded = true
+	return s.value
+}
+
+// credentials attaches the configured credentials to the requests of the endpoints requiring them
+type credentials struct {
+	subscriptionKey *Secret
+	userToken       *Secret
+}
+
+func newCredentials(logger *zap.Logger, conf CredentialsConf) *credentials {
+	logger.Info("Intel services credentials configured",
+		zap.Stringer("subscriptionKey", conf.SubscriptionKey),
+		zap.Stringer("userToken", conf.UserToken))
+	return &credentials{
+		subscriptionKey: NewSecret(logger, "subscriptionKey", conf.SubscriptionKey),
+		userToken:       NewSecret(logger, "userToken", conf.UserToken),
+	}
+}
+
+// apply sets the credential headers of the request sent to the given endpoint. The platform registration and
+// AddPackage requests are accepted anonymously by the Intel RS, but a PCCS only accepts them along with its
+// user token; the PCK retrieval by encrypted PPID requires the PCS subscription key. The secrets are read on
+// every attempt, so a rotated credential is used without restarting the service.
+func (c *credentials) apply(endpointName string, req *http.Request) {
+	switch endpointName {
+	case platformRegistrationEndpointName, addPackageEndpointName:
+		setHeaderIfNotEmpty(req.Header, UserTokenHeader, c.userToken.Get())
+	case pckRetrievalEndpointName:
+		setHeaderIfNotEmpty(req.Header, SubscriptionKeyHeader, c.subscriptionKey.Get())
+	}
+}
+
+func setHeaderIfNotEmpty(header http.Header, key, value string) {
+	if value != "" {
+		header.Set(key, value)
+	}
+}
@@ -5,25 +5,185 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"io"
 	"net/http"
 	"strconv"
+	"time"
 
 	mpmanagement "github.com/opensovereigncloud/cc-intel-platform-registration/internal/pkg/mp_management"
 	sgxplatforminfo "github.com/opensovereigncloud/cc-intel-platform-registration/internal/pkg/sgx_platform_info"
 
 	"github.com/opensovereigncloud/cc-intel-platform-registration/pkg/constants"
 	"github.com/opensovereigncloud/cc-intel-platform-registration/pkg/metrics"
+	pckcerts "github.com/opensovereigncloud/cc-intel-platform-registration/pkg/pck_certs"
+	tcbinfo "github.com/opensovereigncloud/cc-intel-platform-registration/pkg/tcb_info"
 	"go.uber.org/zap"
 )
 
+// maxResponseBodySize bounds the size of the Intel responses read into memory
+const maxResponseBodySize = 4 * 1024 * 1024
+
+const (
+	platformRegistrationEndpointName = "platform_registration"
+	addPackageEndpointName           = "add_package"
+	pckRetrievalEndpointName         = "pck_retrieval"
+	tcbInfoEndpointName              = "tcb_info"
+	pckCrlEndpointName               = "pck_crl"
+)
+
 type IntelService struct {
-	log *zap.Logger
+	log      *zap.Logger
+	config   Config
+	client   *http.Client
+	verifier *pckcerts.Verifier
+	// credentials are attached to every attempt of the requests requiring them
+	credentials *credentials
+	// sleep waits between two attempts, unless the context is done first; it is replaced in tests
+	sleep func(ctx context.Context, delay time.Duration) error
 }
 
-func NewIntelService(logger *zap.Logger) *IntelService {
+func NewIntelService(logger *zap.Logger, config Config) *IntelService {
+	logger.Info("Intel services proxy configured",
+		zap.String("proxyMode", config.Proxy.Type.String()),
+		zap.String("proxyURL", config.Proxy.RedactedURL()),
+		zap.String("noProxy", config.Proxy.NoProxy))
+	metrics.SetIntelProxyMode(config.Proxy.Type.String())
+
+	verifier := pckcerts.NewVerifier(config.SgxRootCA)
+	logger.Info("PCK certificates trust anchor configured",
+		zap.String("subject", verifier.RootCA().Subject.String()),
+		zap.String("sha256Fingerprint", pckcerts.Fingerprint(verifier.RootCA())))
+
 	return &IntelService{
-		log: logger,
+		log:         logger,
+		config:      config,
+		client:      newHTTPClient(config.Proxy),
+		verifier:    verifier,
+		credentials: newCredentials(logger, config.Credentials),
+		sleep:       sleepContext,
+	}
+}
+
+// newHTTPClient creates the HTTP client shared by all the Intel requests, routed according to the proxy configuration
+func newHTTPClient(proxyConf ProxyConf) *http.Client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = proxyConf.proxyFunc()
+	return &http.Client{
+		Timeout:   constants.IntelRequestTimeout,
+		Transport: transport,
+	}
+}
+
+// sleepContext waits for the delay, or returns the context error as soon as the context is done
+func sleepContext(ctx context.Context, delay time.Duration) error {
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// do executes the request, retrying it according to the retry policy when Intel reports a transient failure.
+// A request is retried only when no successful response was received, so a completed operation is never repeated.
+// The returned IntelResponse describes the last attempt, whether it failed or not.
+// No further attempt is made once the context of the request is done, and the pending delay is interrupted.
+func (r *IntelService) do(endpointName string, req *http.Request) (*http.Response, *IntelResponse, error) {
+	ctx := req.Context()
+	policy := r.config.Retry
+	start := time.Now()
+
+	for attempt := 1; ; attempt++ {
+		// every attempt is bounded by the time left, so the last one does not run past the max elapsed time;
+		// the context is released once the response body is closed
+		attemptCtx, cancelAttempt := policy.attemptContext(ctx, start)
+		attemptReq := req.WithContext(attemptCtx)
+		if attempt > 1 && req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				cancelAttempt()
+				return nil, nil, fmt.Errorf("failed to rewind request body: %w", err)
+			}
+			attemptReq = req.Clone(attemptCtx)
+			attemptReq.Body = body
+		}
+
+		r.credentials.apply(endpointName, attemptReq)
+		metrics.IncrementIntelRequestAttempts(endpointName)
+		attemptStart := time.Now()
+		resp, err := r.client.Do(attemptReq)
+		latency := time.Since(attemptStart)
+
+		var retryable bool
+		var delay time.Duration
+		if err != nil {
+			cancelAttempt()
+			retryable = isRetryableError(err, isIdempotentMethod(req.Method))
+			delay = policy.backoff(attempt)
+		} else {
+			resp.Body = cancelOnCloseBody{ReadCloser: resp.Body, cancel: cancelAttempt}
+			retryable = isRetryableStatusCode(resp.StatusCode)
+			retryAfter, ok := parseRetryAfter(resp.Header.Get("Retry-After"), time.Now())
+			if ok {
+				delay = retryAfter
+			} else {
+				delay = policy.backoff(attempt)
+			}
+		}
+
+		if !retryable || ctx.Err() != nil || attempt >= policy.MaxAttempts || time.Since(start)+delay > policy.MaxElapsedTime {
+			metrics.SetIntelRequestLastAttemptCount(endpointName, attempt)
+			intelResponse := newIntelResponse(endpointName, req, resp, err, latency, attempt)
+			r.recordResponse(intelResponse)
+			return resp, intelResponse, err
+		}
+
+		logFields := []zap.Field{
+			zap.String("endpoint", endpointName),
+			zap.Int("attempt", attempt),
+			zap.Duration("delay", delay),
+		}
+		if err != nil {
+			logFields = append(logFields, zap.Error(err))
+		} else {
+			logFields = append(logFields, zap.Int("httpStatusCode", resp.StatusCode))
+			// drain the body so the connection can be reused by the next attempt
+			_, _ = io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}
+		r.log.Warn("Intel request failed with a transient error, retrying", logFields...)
+		if sleepErr := r.sleep(ctx, delay); sleepErr != nil {
+			intelResponse := newIntelResponse(endpointName, req, resp, sleepErr, latency, attempt)
+			r.recordResponse(intelResponse)
+			return nil, intelResponse, sleepErr
+		}
+	}
+}
+
+// recordResponse logs the response of an Intel request and publishes it in the metrics
+func (r *IntelService) recordResponse(intelResponse *IntelResponse) {
+	if intelResponse.HttpStatusCode == 0 || intelResponse.HttpStatusCode >= http.StatusBadRequest {
+		r.log.Warn("Intel request failed", intelResponse.LogFields()...)
+	} else {
+		r.log.Info("Intel request completed", intelResponse.LogFields()...)
 	}
+	metrics.SetIntelRequestLastResponse(intelResponse.Endpoint, intelResponse.HttpStatusCodeString(),
+		intelResponse.RequestID, intelResponse.ErrorCode, intelResponse.ErrorMessage, intelResponse.Latency)
+}
+
+// createStatusCodeMetricForRequestError maps the error of a request which got no response. A request aborted
+// because the check was canceled (e.g. on shutdown) or exceeded its deadline is reported as CheckCanceled, not as
+// an Intel failure; the timeoutStatus is reported when the HTTP client timed out.
+func createStatusCodeMetricForRequestError(ctx context.Context, err error, timeoutStatus metrics.StatusCode) (metrics.StatusCodeMetric, error) {
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return metrics.StatusCodeMetric{Status: metrics.CheckCanceled}, fmt.Errorf("request aborted: %w", ctxErr)
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return metrics.StatusCodeMetric{Status: timeoutStatus}, fmt.Errorf("connection timeout: %w", err)
+	}
+	return metrics.CreateUnknownErrorStatusCodeMetric(), fmt.Errorf("request failed: %w", err)
 }
 
 func createIntelStatusCodeMetricForPlatformRegistration(httpStatusCode int, intelErrorCode string) metrics.StatusCodeMetric {
@@ -56,67 +216,204 @@ func createIntelStatusCodeMetricForDirectRegistration(httpStatusCode int, intelE
 	}
 }
 
-func (r *IntelService) RegisterPlatform(platformManifest mpmanagement.PlatformManifest) (metrics.StatusCodeMetric, error) {
-	client := &http.Client{
-		Timeout: constants.IntelRequestTimeout,
-	}
-
-	req, err := http.NewRequest(http.MethodPost, constants.IntelPlatformRegistrationEndpoint, bytes.NewReader(platformManifest))
+func (r *IntelService) RegisterPlatform(ctx context.Context, platformManifest mpmanagement.PlatformManifest) (metrics.StatusCodeMetric, *IntelResponse, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, r.config.PlatformRegistrationEndpoint(), bytes.NewReader(platformManifest))
 	if err != nil {
-		return metrics.CreateUnknownErrorStatusCodeMetric(), fmt.Errorf("failed to create request: %w", err)
+		return metrics.CreateUnknownErrorStatusCodeMetric(), nil, fmt.Errorf("failed to create request: %w", err)
 	}
 	req.Header.Set("Content-Type", "application/octet-stream")
 
 	// Execute request
-	resp, err := client.Do(req)
+	resp, intelResponse, err := r.do(platformRegistrationEndpointName, req)
 
 	if err != nil {
-		if errors.Is(err, context.DeadlineExceeded) {
-			return metrics.StatusCodeMetric{Status: metrics.IntelConnectFailed}, fmt.Errorf("connection timeout: %w", err)
-		}
-		return metrics.CreateUnknownErrorStatusCodeMetric(), fmt.Errorf("request failed: %w", err)
+		metric, err := createStatusCodeMetricForRequestError(ctx, err, metrics.IntelConnectFailed)
+		return metric, intelResponse, err
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode == http.StatusCreated {
-		return metrics.StatusCodeMetric{Status: metrics.PlatformRebootNeeded}, nil
+		return metrics.StatusCodeMetric{Status: metrics.PlatformRebootNeeded}, intelResponse, nil
 	} else {
-		errorCode := resp.Header.Get("Error-Code")
-		return createIntelStatusCodeMetricForPlatformRegistration(resp.StatusCode, errorCode), nil
+		return createIntelStatusCodeMetricForPlatformRegistration(resp.StatusCode, intelResponse.ErrorCode), intelResponse, nil
 	}
 
 }
 
-func (r *IntelService) RetrievePCK(platformInfo *sgxplatforminfo.SgxPcePlatformInfo) (metrics.StatusCodeMetric, error) {
+func createIntelStatusCodeMetricForAddPackage(httpStatusCode int, intelErrorCode string) metrics.StatusCodeMetric {
+	var Status metrics.StatusCode
+	if httpStatusCode >= http.StatusBadRequest && httpStatusCode < http.StatusInternalServerError {
+		Status = metrics.InvalidAddPackageRequest
+	} else {
+		Status = metrics.IntelRegServiceRequestFailed
+	}
+	return metrics.StatusCodeMetric{
+		Status:         Status,
+		HttpStatusCode: strconv.Itoa(httpStatusCode),
+		IntelError:     intelErrorCode,
+	}
+}
+
+// RegisterAddPackage sends the AddPackage request generated by the BIOS for an added or replaced CPU package to the Intel RS.
+// On success, the returned response (the key blobs of the new package) must be written back to the
+// SgxRegistrationServerResponse UEFI variable for the BIOS to consume it at the next boot.
+func (r *IntelService) RegisterAddPackage(ctx context.Context, addPackageRequest mpmanagement.AddPackageRequest) (metrics.StatusCodeMetric, []byte, *IntelResponse, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, r.config.AddPackageEndpoint(), bytes.NewReader(addPackageRequest))
+	if err != nil {
+		return metrics.CreateUnknownErrorStatusCodeMetric(), nil, nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/octet-stream")
+
+	// Execute request
+	resp, intelResponse, err := r.do(addPackageEndpointName, req)
 
-	client := &http.Client{
-		Timeout: constants.IntelRequestTimeout,
+	if err != nil {
+		metric, err := createStatusCodeMetricForRequestError(ctx, err, metrics.IntelConnectFailed)
+		return metric, nil, intelResponse, err
 	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return createIntelStatusCodeMetricForAddPackage(resp.StatusCode, intelResponse.ErrorCode), nil, intelResponse, nil
+	}
+
+	// the Intel RS accepted the package, so an unusable response is not reported as a failed request: read one
+	// byte more than allowed to detect a response that would not fit in the UEFI variable
+	response, err := io.ReadAll(io.LimitReader(resp.Body, mpmanagement.MPMaxResponseSize+1))
+	if err != nil {
+		return metrics.CreateUnknownErrorStatusCodeMetric(), nil, intelResponse, fmt.Errorf("failed to read add package response: %w", err)
+	}
+	if len(response) == 0 || len(response) > mpmanagement.MPMaxResponseSize {
+		return metrics.CreateUnknownErrorStatusCodeMetric(), nil, intelResponse,
+			fmt.Errorf("invalid add package response size %d, expected between 1 and %d bytes", len(response), mpmanagement.MPMaxResponseSize)
+	}
+
+	return metrics.StatusCodeMetric{Status: metrics.AddPackageRebootNeeded}, response, intelResponse, nil
+}
+
+// RetrievePCK retrieves the PCK certificates of the platform from the PCS.
+// The decoded certificates are returned when the PCS answered with HTTP 200 and they chain up to the trusted root CA.
+func (r *IntelService) RetrievePCK(ctx context.Context, platformInfo *sgxplatforminfo.SgxPcePlatformInfo) (metrics.StatusCodeMetric, *pckcerts.PckCertificates, *IntelResponse, error) {
 
 	requestURL := fmt.Sprintf("%s?encrypted_ppid=%s&pceid=%s",
-		constants.IntelPckRetrievalEndpoint, platformInfo.EncryptedPPID, platformInfo.PCEInfo.PCEID)
-	req, err := http.NewRequest(http.MethodGet, requestURL, http.NoBody)
+		r.config.PckRetrievalEndpoint(), platformInfo.EncryptedPPID, platformInfo.PCEInfo.PCEID)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, requestURL, http.NoBody)
 
 	if err != nil {
-		return metrics.CreateUnknownErrorStatusCodeMetric(), fmt.Errorf("failed to create request: %w", err)
+		return metrics.CreateUnknownErrorStatusCodeMetric(), nil, nil, fmt.Errorf("failed to create request: %w", err)
 	}
 
 	// Execute request
-	resp, err := client.Do(req)
+	resp, intelResponse, err := r.do(pckRetrievalEndpointName, req)
 
 	if err != nil {
-		if errors.Is(err, context.DeadlineExceeded) {
-			return metrics.CreateUnknownErrorStatusCodeMetric(), fmt.Errorf("connection timeout: %w", err)
-		}
-		return metrics.CreateUnknownErrorStatusCodeMetric(), fmt.Errorf("request failed: %w", err)
+		metric, err := createStatusCodeMetricForRequestError(ctx, err, metrics.UnknownError)
+		return metric, nil, intelResponse, err
 	}
 	defer resp.Body.Close()
 
-	if resp.StatusCode == http.StatusOK {
-		return metrics.StatusCodeMetric{Status: metrics.PlatformDirectlyRegistered}, nil
-	} else {
-		errorCode := resp.Header.Get("Error-Code")
-		return createIntelStatusCodeMetricForDirectRegistration(resp.StatusCode, errorCode), nil
+	if resp.StatusCode != http.StatusOK {
+		return createIntelStatusCodeMetricForDirectRegistration(resp.StatusCode, intelResponse.ErrorCode), nil, intelResponse, nil
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxResponseBodySize))
+	if err != nil {
+		return createIntelStatusCodeMetricForDirectRegistration(resp.StatusCode, ""), nil, intelResponse, fmt.Errorf("failed to read PCK certificates: %w", err)
+	}
+	pckCertificates, err := pckcerts.Parse(body, resp.Header)
+	if err != nil {
+		return createIntelStatusCodeMetricForDirectRegistration(resp.StatusCode, ""), nil, intelResponse, err
+	}
+
+	// do not trust a PCS (or anything pretending to be one) that returns certificates not issued by the root CA
+	if err := r.verifier.Verify(pckCertificates); err != nil {
+		return metrics.StatusCodeMetric{
+			Status:         metrics.PckCertChainInvalid,
+			HttpStatusCode: strconv.Itoa(resp.StatusCode),
+		}, nil, intelResponse, err
+	}
+
+	extensions, err := pckCertificates.DecodeSgxExtensions()
+	if err != nil {
+		return metrics.StatusCodeMetric{
+			Status:         metrics.PckCertChainInvalid,
+			HttpStatusCode: strconv.Itoa(resp.StatusCode),
+		}, nil, intelResponse, fmt.Errorf("failed to decode the SGX extension: %w", err)
+	}
+	// the PCS also returns certificates for indirectly registered platforms (e.g. through a PCCS holding the
+	// platform manifest); only the key caching policy tells whether the Intel RS caches the platform root keys
+	if !extensions.IsDirectlyRegistered() {
+		return metrics.StatusCodeMetric{
+			Status:         metrics.SgxResetNeeded,
+			HttpStatusCode: strconv.Itoa(resp.StatusCode),
+		}, pckCertificates, intelResponse, nil
 	}
 
+	return metrics.StatusCodeMetric{Status: metrics.PlatformDirectlyRegistered}, pckCertificates, intelResponse, nil
+}
+
+// RetrieveTcbInfo retrieves the TCB Info of the FMSPC from the PCS.
+// The TCB Info is returned only when its signature chains up to the trusted root CA and it is issued for the FMSPC.
+func (r *IntelService) RetrieveTcbInfo(ctx context.Context, fmspc string) (*tcbinfo.TcbInfo, *IntelResponse, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, fmt.Sprintf("%s?fmspc=%s", r.config.TcbInfoEndpoint(), fmspc), http.NoBody)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	// Execute request
+	resp, intelResponse, err := r.do(tcbInfoEndpointName, req)
+	if err != nil {
+		return nil, intelResponse, fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, intelResponse, fmt.Errorf("unexpected HTTP status code %d", resp.StatusCode)
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxResponseBodySize))
+	if err != nil {
+		return nil, intelResponse, fmt.Errorf("failed to read TCB Info: %w", err)
+	}
+	signedTcbInfo, err := tcbinfo.Parse(body, resp.Header)
+	if err != nil {
+		return nil, intelResponse, err
+	}
+	if err := signedTcbInfo.Verify(r.verifier, fmspc); err != nil {
+		return nil, intelResponse, err
+	}
+	return &signedTcbInfo.TcbInfo, intelResponse, nil
+}
+
+// RetrievePckCrl retrieves the CRL of the PCK CA of the given type (processor or platform) from the PCS.
+// The CRL is returned only when it is signed by a PCK CA chaining up to the trusted root CA.
+func (r *IntelService) RetrievePckCrl(ctx context.Context, caType string) (*pckcerts.PckCrl, *IntelResponse, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, fmt.Sprintf("%s?ca=%s", r.config.PckCrlEndpoint(), caType), http.NoBody)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	// Execute request
+	resp, intelResponse, err := r.do(pckCrlEndpointName, req)
+	if err != nil {
+		return nil, intelResponse, fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, intelResponse, fmt.Errorf("unexpected HTTP status code %d", resp.StatusCode)
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxResponseBodySize))
+	if err != nil {
+		return nil, intelResponse, fmt.Errorf("failed to read PCK CRL: %w", err)
+	}
+	pckCrl, err := pckcerts.ParseCrl(caType, body, resp.Header)
+	if err != nil {
+		return nil, intelResponse, err
+	}
+	if err := r.verifier.VerifyCrl(pckCrl); err != nil {
+		return nil, intelResponse, err
+	}
+	return pckCrl, intelResponse, nil
 }
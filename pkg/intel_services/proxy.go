@@ -0,0 +1,130 @@
+package intelservices
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"golang.org/x/net/http/httpproxy"
+)
+
+// ProxyType mirrors the ProxyType enum defined in MultiPackageDefs.h
+type ProxyType int
+
+const (
+	// ProxyTypeDefault uses the HTTP_PROXY, HTTPS_PROXY and NO_PROXY environment variables (MP_REG_PROXY_TYPE_DEFAULT_PROXY)
+	ProxyTypeDefault ProxyType = 0
+	// ProxyTypeDirect connects to the Intel services without a proxy (MP_REG_PROXY_TYPE_DIRECT_ACCESS)
+	ProxyTypeDirect ProxyType = 1
+	// ProxyTypeManual uses the explicitly configured proxy URL (MP_REG_PROXY_TYPE_MANUAL_PROXY)
+	ProxyTypeManual ProxyType = 2
+)
+
+func (p ProxyType) String() string {
+	switch p {
+	case ProxyTypeDefault:
+		return "default"
+	case ProxyTypeDirect:
+		return "direct"
+	case ProxyTypeManual:
+		return "manual"
+	default:
+		return "unknown"
+	}
+}
+
+// ParseProxyType converts the textual representation of a proxy type (default, direct or manual) to a ProxyType
+func ParseProxyType(value string) (ProxyType, error) {
+	for _, proxyType := range []ProxyType{ProxyTypeDefault, ProxyTypeDirect, ProxyTypeManual} {
+		if strings.EqualFold(value, proxyType.String()) {
+			return proxyType, nil
+		}
+	}
+	return ProxyTypeDefault, fmt.Errorf("unsupported proxy type %q, expected one of default, direct, manual", value)
+}
+
+// ProxyConf mirrors the ProxyConf struct defined in MultiPackageDefs.h.
+// It is extended with the proxy credentials and a NO_PROXY list, which only apply to the manual proxy type.
+type ProxyConf struct {
+	Type ProxyType
+	URL  string
+	// Username and Password are optional; they take precedence over the credentials embedded in URL
+	Username string
+	Password string
+	// NoProxy is a comma-separated list of hosts, domains and CIDRs that bypass the manual proxy
+	NoProxy string
+}
+
+// Validate checks that a manual proxy has a valid URL
+func (p ProxyConf) Validate() error {
+	switch p.Type {
+	case ProxyTypeDefault, ProxyTypeDirect:
+		return nil
+	case ProxyTypeManual:
+		if p.URL == "" {
+			return fmt.Errorf("manual proxy type requires a proxy URL")
+		}
+		proxyURL, err := url.Parse(p.URL)
+		if err != nil {
+			return fmt.Errorf("invalid proxy URL: %w", err)
+		}
+		if proxyURL.Scheme != "http" && proxyURL.Scheme != "https" && proxyURL.Scheme != "socks5" {
+			return fmt.Errorf("proxy URL must use the http, https or socks5 scheme")
+		}
+		if proxyURL.Host == "" {
+			return fmt.Errorf("proxy URL has no host")
+		}
+		return nil
+	default:
+		return fmt.Errorf("unsupported proxy type %d", p.Type)
+	}
+}
+
+// RedactedURL returns the proxy URL with any password replaced, suitable for logging
+func (p ProxyConf) RedactedURL() string {
+	proxyURL, err := p.proxyURL()
+	if err != nil || proxyURL == nil {
+		return ""
+	}
+	return proxyURL.Redacted()
+}
+
+func (p ProxyConf) proxyURL() (*url.URL, error) {
+	if p.Type != ProxyTypeManual || p.URL == "" {
+		return nil, nil
+	}
+	proxyURL, err := url.Parse(p.URL)
+	if err != nil {
+		return nil, err
+	}
+	if p.Username != "" {
+		proxyURL.User = url.UserPassword(p.Username, p.Password)
+	}
+	return proxyURL, nil
+}
+
+// proxyFunc returns the function used by the http.Transport to select the proxy of each request
+func (p ProxyConf) proxyFunc() func(*http.Request) (*url.URL, error) {
+	switch p.Type {
+	case ProxyTypeDirect:
+		return nil
+	case ProxyTypeManual:
+		proxyURL, err := p.proxyURL()
+		if err != nil {
+			return func(*http.Request) (*url.URL, error) {
+				return nil, fmt.Errorf("invalid proxy URL: %w", err)
+			}
+		}
+		proxyFunc := (&httpproxy.Config{
+			HTTPProxy:  proxyURL.String(),
+			HTTPSProxy: proxyURL.String(),
+			NoProxy:    p.NoProxy,
+		}).ProxyFunc()
+		return func(req *http.Request) (*url.URL, error) {
+			return proxyFunc(req.URL)
+		}
+	default:
+		return http.ProxyFromEnvironment
+	}
+}
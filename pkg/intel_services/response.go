@@ -0,0 +1,84 @@
+package intelservices
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// Intel response headers
+const (
+	RequestIDHeader    = "Request-ID"
+	ErrorCodeHeader    = "Error-Code"
+	ErrorMessageHeader = "Error-Message"
+)
+
+// IntelResponse describes the outcome of a request to the Intel services, holding what Intel support
+// asks for to investigate a failure
+type IntelResponse struct {
+	// Endpoint is the name of the requested endpoint, e.g. platform_registration
+	Endpoint string
+	// URL is the requested URL, without its query
+	URL string
+	// HttpStatusCode is 0 when no response was received
+	HttpStatusCode int
+	RequestID      string
+	ErrorCode      string
+	ErrorMessage   string
+	// Error is the transport error of the last attempt, when no response was received
+	Error string
+	// Latency is the duration of the last attempt
+	Latency  time.Duration
+	Attempts int
+	// Time is when the last attempt completed
+	Time time.Time
+}
+
+func newIntelResponse(endpointName string, req *http.Request, resp *http.Response, err error, latency time.Duration, attempts int) *IntelResponse {
+	intelResponse := &IntelResponse{
+		Endpoint: endpointName,
+		URL:      req.URL.Scheme + "://" + req.URL.Host + req.URL.Path,
+		Latency:  latency,
+		Attempts: attempts,
+		Time:     time.Now(),
+	}
+	if err != nil {
+		intelResponse.Error = err.Error()
+	}
+	if resp != nil {
+		intelResponse.HttpStatusCode = resp.StatusCode
+		intelResponse.RequestID = resp.Header.Get(RequestIDHeader)
+		intelResponse.ErrorCode = resp.Header.Get(ErrorCodeHeader)
+		intelResponse.ErrorMessage = resp.Header.Get(ErrorMessageHeader)
+	}
+	return intelResponse
+}
+
+// HttpStatusCodeString returns the HTTP status code as reported in the metrics, or an empty string when no response was received
+func (r *IntelResponse) HttpStatusCodeString() string {
+	if r.HttpStatusCode == 0 {
+		return ""
+	}
+	return strconv.Itoa(r.HttpStatusCode)
+}
+
+// LogFields returns the structured log fields of the response
+func (r *IntelResponse) LogFields() []zap.Field {
+	fields := []zap.Field{
+		zap.String("endpoint", r.Endpoint),
+		zap.String("url", r.URL),
+		zap.Int("httpStatusCode", r.HttpStatusCode),
+		zap.String("requestId", r.RequestID),
+		zap.Duration("latency", r.Latency),
+		zap.Int("attempts", r.Attempts),
+	}
+	if r.ErrorCode != "" || r.ErrorMessage != "" {
+		fields = append(fields, zap.String("intelErrorCode", r.ErrorCode), zap.String("intelErrorMessage", r.ErrorMessage))
+	}
+	if r.Error != "" {
+		fields = append(fields, zap.String("error", r.Error))
+	}
+	return fields
+}
@@ -0,0 +1,159 @@
+package intelservices
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+// RetryPolicy bounds how transient failures of the Intel requests are retried
+type RetryPolicy struct {
+	// MaxAttempts is the maximum number of attempts per request, including the first one
+	MaxAttempts int
+	// InitialBackoff is the base delay before the first retry; it doubles for every further retry
+	InitialBackoff time.Duration
+	// MaxBackoff caps the exponential backoff delay
+	MaxBackoff time.Duration
+	// MaxElapsedTime bounds the total time spent on a request, including the waits between attempts
+	MaxElapsedTime time.Duration
+}
+
+// DefaultRetryPolicy returns the retry policy used when none is configured
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts:    4,
+		InitialBackoff: 2 * time.Second,
+		MaxBackoff:     30 * time.Second,
+		MaxElapsedTime: 5 * time.Minute,
+	}
+}
+
+// Validate checks that the retry policy bounds are consistent
+func (p RetryPolicy) Validate() error {
+	if p.MaxAttempts < 1 {
+		return fmt.Errorf("max attempts must be at least 1, got %d", p.MaxAttempts)
+	}
+	if p.InitialBackoff <= 0 || p.MaxBackoff <= 0 || p.MaxElapsedTime <= 0 {
+		return fmt.Errorf("backoff durations and max elapsed time must be positive")
+	}
+	if p.InitialBackoff > p.MaxBackoff {
+		return fmt.Errorf("initial backoff %s exceeds max backoff %s", p.InitialBackoff, p.MaxBackoff)
+	}
+	return nil
+}
+
+// backoff returns the delay before the given retry (1 for the first retry): an exponential delay
+// capped at MaxBackoff, of which the upper half is randomized to spread the retries of a fleet of nodes
+func (p RetryPolicy) backoff(retry int) time.Duration {
+	delay := p.MaxBackoff
+	if shift := retry - 1; shift < 32 {
+		if exponential := p.InitialBackoff << shift; exponential > 0 && exponential < p.MaxBackoff {
+			delay = exponential
+		}
+	}
+	half := delay / 2
+	return half + rand.N(half+1)
+}
+
+// attemptContext returns the context of an attempt of a request started at start, bounded by the max elapsed time
+func (p RetryPolicy) attemptContext(ctx context.Context, start time.Time) (context.Context, context.CancelFunc) {
+	if p.MaxElapsedTime <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithDeadline(ctx, start.Add(p.MaxElapsedTime))
+}
+
+// isRetryableStatusCode reports whether an Intel response signals a transient failure.
+// Successful responses are never retried, so an operation that already succeeded is never repeated.
+func isRetryableStatusCode(statusCode int) bool {
+	switch statusCode {
+	case http.StatusRequestTimeout,
+		http.StatusTooManyRequests,
+		http.StatusInternalServerError,
+		http.StatusBadGateway,
+		http.StatusServiceUnavailable,
+		http.StatusGatewayTimeout:
+		return true
+	default:
+		return false
+	}
+}
+
+// isRetryableError reports whether a transport error is transient (connection reset or refused,
+// unexpected EOF, timeouts or temporary DNS failures). The request of a non-idempotent method (the POST
+// registration requests) may have been processed by Intel despite the error, so it is only retried when the
+// error was raised before the request was sent, i.e. when the connection could not be established.
+func isRetryableError(err error, idempotent bool) bool {
+	if !idempotent {
+		return isConnectError(err) && isRetryableError(err, true)
+	}
+	if errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, syscall.ECONNREFUSED) ||
+		errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, io.EOF) {
+		return true
+	}
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) {
+		return dnsErr.IsTemporary || dnsErr.IsTimeout
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
+
+// isConnectError reports whether a transport error was raised while establishing the connection (DNS
+// resolution, dial or proxy tunnel), before the request was sent
+func isConnectError(err error) bool {
+	var opErr *net.OpError
+	return errors.As(err, &opErr) && (opErr.Op == "dial" || opErr.Op == "proxyconnect")
+}
+
+// isIdempotentMethod reports whether an HTTP method can be repeated without side effects
+func isIdempotentMethod(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return true
+	default:
+		return false
+	}
+}
+
+// cancelOnCloseBody releases the context of a request attempt once its response body is closed
+type cancelOnCloseBody struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b cancelOnCloseBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
+
+// parseRetryAfter parses a Retry-After header value, given either in seconds or as an HTTP date
+func parseRetryAfter(value string, now time.Time) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0, false
+		}
+		return time.Duration(seconds) * time.Second, true
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		delay := date.Sub(now)
+		if delay < 0 {
+			delay = 0
+		}
+		return delay, true
+	}
+	return 0, false
+}
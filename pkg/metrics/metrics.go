@@ -2,6 +2,8 @@ package metrics
 
 import (
 	"fmt"
+	"strings"
+	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promauto"
@@ -12,10 +14,35 @@ const (
 	// metrics definitions
 	RegistrationServiceStatusCodeMetricValue  = "service_status_code"
 	RegistrationServicePanicCountsMetricValue = "application_panics_total"
+	IntelProxyModeMetricValue                 = "intel_proxy_mode"
+	IntelRequestAttemptsMetricValue           = "intel_request_attempts_total"
+	IntelRequestLastAttemptCountMetricValue   = "intel_request_last_attempt_count"
+	PckCertificateTcbLevelMetricValue         = "pck_certificate_tcb_level"
+	SgxPlatformInfoMetricValue                = "sgx_platform_info"
+	IntelRequestLastResponseMetricValue       = "intel_request_last_response_info"
+	IntelRequestLastLatencyMetricValue        = "intel_request_last_latency_seconds"
+	TcbStatusMetricValue                      = "tcb_status"
+	TcbAdvisoryMetricValue                    = "tcb_advisory"
+	TcbEvaluationDataNumberMetricValue        = "tcb_evaluation_data_number"
+	PckCrlAgeMetricValue                      = "pck_crl_age_seconds"
+	PckCrlNextUpdateMetricValue               = "pck_crl_next_update_timestamp_seconds"
+	RegistrationCompletedMetricValue          = "registration_completed_timestamp_seconds"
+	TcbRecoveryRegistrationsMetricValue       = "tcb_recovery_registrations_total"
+	NextCheckMetricValue                      = "next_check_timestamp_seconds"
 
 	// label definitions
-	HttpStatusCodeLabel = "http_status_code"
-	IntelErrorCodeLabel = "intel_error_code"
+	HttpStatusCodeLabel    = "http_status_code"
+	IntelErrorCodeLabel    = "intel_error_code"
+	ProxyModeLabel         = "mode"
+	EndpointLabel          = "endpoint"
+	TcbmLabel              = "tcbm"
+	FmspcLabel             = "fmspc"
+	SgxTypeLabel           = "sgx_type"
+	RequestIDLabel         = "request_id"
+	IntelErrorMessageLabel = "intel_error_message"
+	TcbStatusLabel         = "status"
+	AdvisoryIDLabel        = "advisory_id"
+	CaTypeLabel            = "ca"
 )
 
 // Define a custom type for status codes
@@ -33,10 +60,16 @@ const (
 	SgxResetNeeded               StatusCode = 3
 	UefiPersistFailed            StatusCode = 4
 	PlatformRebootNeeded         StatusCode = 5
+	AddPackageRebootNeeded       StatusCode = 6
+	TcbRecoveryRegistered        StatusCode = 7
+	PlatformRevoked              StatusCode = 8
 	PlatformDirectlyRegistered   StatusCode = 9
 	IntelConnectFailed           StatusCode = 10
 	InvalidRegistrationRequest   StatusCode = 11
 	IntelRegServiceRequestFailed StatusCode = 12
+	PckCertChainInvalid          StatusCode = 13
+	InvalidAddPackageRequest     StatusCode = 14
+	CheckCanceled                StatusCode = 98
 	UnknownError                 StatusCode = 99
 )
 
@@ -47,7 +80,7 @@ func (s StatusCode) GetDetails() StatusCodeDetails {
 			RequiresHTTPStatusCode: true,
 			RequiresIntelErrCode:   true,
 		}
-	case IntelRegServiceRequestFailed, SgxResetNeeded:
+	case IntelRegServiceRequestFailed, SgxResetNeeded, PckCertChainInvalid, InvalidAddPackageRequest:
 		return StatusCodeDetails{
 			RequiresHTTPStatusCode: true,
 			RequiresIntelErrCode:   false,
@@ -61,6 +94,22 @@ func (s StatusCode) GetDetails() StatusCodeDetails {
 
 }
 
+// RebootNeeded reports whether the registration is completed and waits for a reboot (05, 06 or 07)
+func (s StatusCode) RebootNeeded() bool {
+	switch s {
+	case PlatformRebootNeeded, AddPackageRebootNeeded, TcbRecoveryRegistered:
+		return true
+	default:
+		return false
+	}
+}
+
+// Name returns the name of the status code without its description, e.g. PlatformRebootNeeded
+func (s StatusCode) Name() string {
+	name, _, _ := strings.Cut(s.String(), ":")
+	return name
+}
+
 func (s StatusCode) toInt() int {
 	return int(s)
 }
@@ -78,8 +127,14 @@ func (s StatusCode) String() string {
 		return "SgxResetNeeded: impossible to determine the registration status; please reset the SGX"
 	case PlatformRebootNeeded:
 		return "PlatformRebootNeeded: platform registered successfully and a reboot is required"
+	case AddPackageRebootNeeded:
+		return "AddPackageRebootNeeded: added CPU package registered successfully and a reboot is required"
+	case TcbRecoveryRegistered:
+		return "TcbRecoveryRegistered: new platform manifest registered successfully after a TCB recovery or an SGX reset and a reboot is required"
 	case UefiPersistFailed:
 		return "UefiPersistFailed: failed to persist the UEFI variable content"
+	case PlatformRevoked:
+		return "PlatformRevoked: the PCK certificate of the platform is revoked"
 	case PlatformDirectlyRegistered:
 		return "PlatformDirectlyRegistered: platform directly registered"
 	case IntelConnectFailed:
@@ -88,6 +143,12 @@ func (s StatusCode) String() string {
 		return "InvalidRegistrationRequest: invalid registration request"
 	case IntelRegServiceRequestFailed:
 		return "IntelRegServiceRequestFailed: intel RS could not process the request"
+	case PckCertChainInvalid:
+		return "PckCertChainInvalid: the PCK certificate chain is invalid or expired"
+	case InvalidAddPackageRequest:
+		return "InvalidAddPackageRequest: invalid add package request"
+	case CheckCanceled:
+		return "CheckCanceled: the registration check was canceled or exceeded its deadline"
 	default:
 		return "UnknownError"
 	}
@@ -99,6 +160,21 @@ type StatusCodeMetric struct {
 	IntelError     string
 }
 
+// Validate checks that the metric carries the HTTP status code and Intel error code its status requires
+func (m StatusCodeMetric) Validate() error {
+	statusDetails := m.Status.GetDetails()
+	if statusDetails.RequiresHTTPStatusCode && m.HttpStatusCode == "" {
+		return fmt.Errorf("warning: status code %d requires HTTP status code but none provided",
+			m.Status)
+	}
+
+	if statusDetails.RequiresIntelErrCode && m.IntelError == "" {
+		return fmt.Errorf("warning: Status code %d requires Intel Error code but none provided",
+			m.Status)
+	}
+	return nil
+}
+
 func CreateUnknownErrorStatusCodeMetric() StatusCodeMetric {
 	return StatusCodeMetric{
 		Status: UnknownError,
@@ -129,8 +205,201 @@ var (
 		Name: RegistrationServicePanicCountsMetricValue,
 		Help: "Total number of go routines panics",
 	})
+
+	IntelProxyModeMetric = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: IntelProxyModeMetricValue,
+			Help: "Proxy mode used for the outbound Intel traffic; the active mode is set to 1",
+		},
+		[]string{ProxyModeLabel},
+	)
+
+	IntelRequestAttemptsMetric = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: IntelRequestAttemptsMetricValue,
+			Help: "Total number of attempts of the requests to the Intel services, including retries",
+		},
+		[]string{EndpointLabel},
+	)
+
+	IntelRequestLastAttemptCountMetric = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: IntelRequestLastAttemptCountMetricValue,
+			Help: "Number of attempts needed by the last request to the Intel services",
+		},
+		[]string{EndpointLabel},
+	)
+
+	PckCertificateTcbLevelMetric = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: PckCertificateTcbLevelMetricValue,
+			Help: "TCB levels (TCBm) for which the platform has a PCK certificate; each available level is set to 1",
+		},
+		[]string{TcbmLabel},
+	)
+
+	IntelRequestLastResponseMetric = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: IntelRequestLastResponseMetricValue,
+			Help: "Last response of the Intel services per endpoint, with the Intel Request-ID, Error-Code and Error-Message; always set to 1",
+		},
+		[]string{EndpointLabel, HttpStatusCodeLabel, RequestIDLabel, IntelErrorCodeLabel, IntelErrorMessageLabel},
+	)
+
+	IntelRequestLastLatencyMetric = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: IntelRequestLastLatencyMetricValue,
+			Help: "Latency in seconds of the last attempt of the last request to the Intel services",
+		},
+		[]string{EndpointLabel},
+	)
+
+	SgxPlatformInfoMetric = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: SgxPlatformInfoMetricValue,
+			Help: "Platform properties decoded from the SGX extension of the PCK certificates; always set to 1",
+		},
+		[]string{FmspcLabel, SgxTypeLabel},
+	)
+
+	TcbStatusMetric = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: TcbStatusMetricValue,
+			Help: "TCB status of the platform (UpToDate, SWHardeningNeeded, OutOfDate, Revoked, ...) according to the TCB Info of its FMSPC; always set to 1",
+		},
+		[]string{TcbStatusLabel, TcbmLabel},
+	)
+
+	TcbAdvisoryMetric = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: TcbAdvisoryMetricValue,
+			Help: "Intel security advisories affecting the TCB level of the platform; each advisory is set to 1",
+		},
+		[]string{AdvisoryIDLabel},
+	)
+
+	TcbEvaluationDataNumberMetric = promauto.NewGauge(
+		prometheus.GaugeOpts{
+			Name: TcbEvaluationDataNumberMetricValue,
+			Help: "TCB evaluation data number of the TCB Info the TCB status was evaluated against",
+		},
+	)
+
+	PckCrlAgeMetric = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: PckCrlAgeMetricValue,
+			Help: "Age in seconds (since its thisUpdate) of the PCK CRL the platform PCK certificates were last checked against, per PCK CA",
+		},
+		[]string{CaTypeLabel},
+	)
+
+	PckCrlNextUpdateMetric = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: PckCrlNextUpdateMetricValue,
+			Help: "Unix timestamp of the nextUpdate of the PCK CRL the platform PCK certificates were last checked against, per PCK CA",
+		},
+		[]string{CaTypeLabel},
+	)
+
+	RegistrationCompletedMetric = promauto.NewGauge(
+		prometheus.GaugeOpts{
+			Name: RegistrationCompletedMetricValue,
+			Help: "Unix timestamp of the completion of the registration while the platform waits for a reboot; 0 when no reboot is pending",
+		},
+	)
+
+	TcbRecoveryRegistrationsMetric = promauto.NewCounter(prometheus.CounterOpts{
+		Name: TcbRecoveryRegistrationsMetricValue,
+		Help: "Total number of new platform manifests registered after a TCB recovery or an SGX reset",
+	})
+
+	NextCheckMetric = promauto.NewGauge(
+		prometheus.GaugeOpts{
+			Name: NextCheckMetricValue,
+			Help: "Unix timestamp of the next scheduled registration check",
+		},
+	)
 )
 
+// SetNextCheck publishes when the next registration check is scheduled
+func SetNextCheck(nextCheckAt time.Time) {
+	NextCheckMetric.Set(float64(nextCheckAt.Unix()))
+}
+
+// IncTcbRecoveryRegistrations counts a new platform manifest registered after a TCB recovery or an SGX reset
+func IncTcbRecoveryRegistrations() {
+	TcbRecoveryRegistrationsMetric.Inc()
+}
+
+// SetRegistrationCompleted publishes when the registration was completed, or 0 when no reboot is pending
+func SetRegistrationCompleted(completedAt time.Time) {
+	if completedAt.IsZero() {
+		RegistrationCompletedMetric.Set(0)
+		return
+	}
+	RegistrationCompletedMetric.Set(float64(completedAt.Unix()))
+}
+
+// SetPckCrl publishes the validity of the PCK CRL of the given PCK CA
+func SetPckCrl(caType string, thisUpdate, nextUpdate time.Time) {
+	PckCrlAgeMetric.With(prometheus.Labels{CaTypeLabel: caType}).Set(time.Since(thisUpdate).Seconds())
+	PckCrlNextUpdateMetric.With(prometheus.Labels{CaTypeLabel: caType}).Set(float64(nextUpdate.Unix()))
+}
+
+// SetTcbEvaluation replaces the TCB status of the platform and the advisories affecting it
+func SetTcbEvaluation(status, tcbm string, advisoryIDs []string, tcbEvaluationDataNumber int) {
+	TcbStatusMetric.Reset()
+	TcbStatusMetric.With(prometheus.Labels{TcbStatusLabel: status, TcbmLabel: tcbm}).Set(1)
+	TcbAdvisoryMetric.Reset()
+	for _, advisoryID := range advisoryIDs {
+		TcbAdvisoryMetric.With(prometheus.Labels{AdvisoryIDLabel: advisoryID}).Set(1)
+	}
+	TcbEvaluationDataNumberMetric.Set(float64(tcbEvaluationDataNumber))
+}
+
+// SetIntelRequestLastResponse replaces the description of the last response of the given Intel endpoint
+func SetIntelRequestLastResponse(endpoint, httpStatusCode, requestID, intelErrorCode, intelErrorMessage string, latency time.Duration) {
+	IntelRequestLastResponseMetric.DeletePartialMatch(prometheus.Labels{EndpointLabel: endpoint})
+	IntelRequestLastResponseMetric.With(prometheus.Labels{
+		EndpointLabel:          endpoint,
+		HttpStatusCodeLabel:    httpStatusCode,
+		RequestIDLabel:         requestID,
+		IntelErrorCodeLabel:    intelErrorCode,
+		IntelErrorMessageLabel: intelErrorMessage,
+	}).Set(1)
+	IntelRequestLastLatencyMetric.With(prometheus.Labels{EndpointLabel: endpoint}).Set(latency.Seconds())
+}
+
+// SetSgxPlatformInfo replaces the platform properties decoded from the PCK certificates
+func SetSgxPlatformInfo(fmspc, sgxType string) {
+	SgxPlatformInfoMetric.Reset()
+	SgxPlatformInfoMetric.With(prometheus.Labels{FmspcLabel: fmspc, SgxTypeLabel: sgxType}).Set(1)
+}
+
+// SetPckCertificateTcbLevels replaces the TCB levels for which the platform has a PCK certificate
+func SetPckCertificateTcbLevels(tcbms []string) {
+	PckCertificateTcbLevelMetric.Reset()
+	for _, tcbm := range tcbms {
+		PckCertificateTcbLevelMetric.With(prometheus.Labels{TcbmLabel: tcbm}).Set(1)
+	}
+}
+
+// IncrementIntelRequestAttempts counts one attempt of a request to the given Intel endpoint
+func IncrementIntelRequestAttempts(endpoint string) {
+	IntelRequestAttemptsMetric.With(prometheus.Labels{EndpointLabel: endpoint}).Inc()
+}
+
+// SetIntelRequestLastAttemptCount records how many attempts the last request to the given Intel endpoint needed
+func SetIntelRequestLastAttemptCount(endpoint string, attempts int) {
+	IntelRequestLastAttemptCountMetric.With(prometheus.Labels{EndpointLabel: endpoint}).Set(float64(attempts))
+}
+
+// SetIntelProxyMode marks the given proxy mode as the active one
+func SetIntelProxyMode(mode string) {
+	IntelProxyModeMetric.Reset()
+	IntelProxyModeMetric.With(prometheus.Labels{ProxyModeLabel: mode}).Set(1)
+}
+
 // helper function to service status code to pending
 func IncrementPanicCounts() {
 	RegistrationServicePanicCountsMetric.Inc()
@@ -148,15 +417,8 @@ func (s *RegistrationServiceMetricsRegistry) SetServiceStatusCodeToPending() err
 
 func (s *RegistrationServiceMetricsRegistry) UpdateServiceStatusCodeMetric(metricValue StatusCodeMetric) error {
 	// Validate required labels
-	statusDetails := metricValue.Status.GetDetails()
-	if statusDetails.RequiresHTTPStatusCode && metricValue.HttpStatusCode == "" {
-		return fmt.Errorf("warning: status code %d requires HTTP status code but none provided",
-			metricValue.Status)
-	}
-
-	if statusDetails.RequiresIntelErrCode && metricValue.IntelError == "" {
-		return fmt.Errorf("warning: Status code %d requires Intel Error code but none provided",
-			metricValue.Status)
+	if err := metricValue.Validate(); err != nil {
+		return err
 	}
 	// Set the new metric value with labels
 	RegistrationServiceStatusCodeMetric.With(prometheus.Labels{
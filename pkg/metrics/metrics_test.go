@@ -71,6 +71,24 @@ func TestGetDetails(t *testing.T) {
 			},
 			wantedIntValue: 5,
 		},
+		{
+			msg:        "TcbRecoveryRegistered returns the expected details",
+			statusCode: TcbRecoveryRegistered,
+			wantedDetails: StatusCodeDetails{
+				RequiresHTTPStatusCode: false,
+				RequiresIntelErrCode:   false,
+			},
+			wantedIntValue: 7,
+		},
+		{
+			msg:        "PlatformRevoked returns the expected details",
+			statusCode: PlatformRevoked,
+			wantedDetails: StatusCodeDetails{
+				RequiresHTTPStatusCode: false,
+				RequiresIntelErrCode:   false,
+			},
+			wantedIntValue: 8,
+		},
 		{
 			msg:        "PlatformDirectlyRegistered returns the expected details",
 			statusCode: PlatformDirectlyRegistered,
@@ -107,6 +125,42 @@ func TestGetDetails(t *testing.T) {
 			},
 			wantedIntValue: 12,
 		},
+		{
+			msg:        "PckCertChainInvalid returns the expected details",
+			statusCode: PckCertChainInvalid,
+			wantedDetails: StatusCodeDetails{
+				RequiresHTTPStatusCode: true,
+				RequiresIntelErrCode:   false,
+			},
+			wantedIntValue: 13,
+		},
+		{
+			msg:        "AddPackageRebootNeeded returns the expected details",
+			statusCode: AddPackageRebootNeeded,
+			wantedDetails: StatusCodeDetails{
+				RequiresHTTPStatusCode: false,
+				RequiresIntelErrCode:   false,
+			},
+			wantedIntValue: 6,
+		},
+		{
+			msg:        "InvalidAddPackageRequest returns the expected details",
+			statusCode: InvalidAddPackageRequest,
+			wantedDetails: StatusCodeDetails{
+				RequiresHTTPStatusCode: true,
+				RequiresIntelErrCode:   false,
+			},
+			wantedIntValue: 14,
+		},
+		{
+			msg:        "CheckCanceled returns the expected details",
+			statusCode: CheckCanceled,
+			wantedDetails: StatusCodeDetails{
+				RequiresHTTPStatusCode: false,
+				RequiresIntelErrCode:   false,
+			},
+			wantedIntValue: 98,
+		},
 		{
 			msg:        "UnknownError returns the expected details",
 			statusCode: UnknownError,
@@ -161,6 +215,16 @@ func TestGetStatusCodeString(t *testing.T) {
 			statusCode:   PlatformRebootNeeded,
 			wantedString: "PlatformRebootNeeded: platform registered successfully and a reboot is required",
 		},
+		{
+			msg:          "TcbRecoveryRegistered returns the expected details",
+			statusCode:   TcbRecoveryRegistered,
+			wantedString: "TcbRecoveryRegistered: new platform manifest registered successfully after a TCB recovery or an SGX reset and a reboot is required",
+		},
+		{
+			msg:          "PlatformRevoked returns the expected details",
+			statusCode:   PlatformRevoked,
+			wantedString: "PlatformRevoked: the PCK certificate of the platform is revoked",
+		},
 		{
 			msg:          "PlatformDirectlyRegistered returns the expected details",
 			statusCode:   PlatformDirectlyRegistered,
@@ -181,6 +245,26 @@ func TestGetStatusCodeString(t *testing.T) {
 			statusCode:   IntelRegServiceRequestFailed,
 			wantedString: "IntelRegServiceRequestFailed: intel RS could not process the request",
 		},
+		{
+			msg:          "PckCertChainInvalid returns the expected details",
+			statusCode:   PckCertChainInvalid,
+			wantedString: "PckCertChainInvalid: the PCK certificate chain is invalid or expired",
+		},
+		{
+			msg:          "AddPackageRebootNeeded returns the expected details",
+			statusCode:   AddPackageRebootNeeded,
+			wantedString: "AddPackageRebootNeeded: added CPU package registered successfully and a reboot is required",
+		},
+		{
+			msg:          "InvalidAddPackageRequest returns the expected details",
+			statusCode:   InvalidAddPackageRequest,
+			wantedString: "InvalidAddPackageRequest: invalid add package request",
+		},
+		{
+			msg:          "CheckCanceled returns the expected details",
+			statusCode:   CheckCanceled,
+			wantedString: "CheckCanceled: the registration check was canceled or exceeded its deadline",
+		},
 		{
 			msg:          "UnknownError returns the expected details",
 			statusCode:   UnknownError,
@@ -194,6 +278,43 @@ func TestGetStatusCodeString(t *testing.T) {
 	}
 }
 
+func TestStatusCodeRebootNeeded(t *testing.T) {
+	cases := []struct {
+		msg                string
+		statusCode         StatusCode
+		wantedRebootNeeded bool
+	}{
+		{msg: "PlatformRebootNeeded waits for a reboot", statusCode: PlatformRebootNeeded, wantedRebootNeeded: true},
+		{msg: "AddPackageRebootNeeded waits for a reboot", statusCode: AddPackageRebootNeeded, wantedRebootNeeded: true},
+		{msg: "TcbRecoveryRegistered waits for a reboot", statusCode: TcbRecoveryRegistered, wantedRebootNeeded: true},
+		{msg: "PlatformDirectlyRegistered does not wait for a reboot", statusCode: PlatformDirectlyRegistered},
+		{msg: "SgxResetNeeded does not wait for a reboot", statusCode: SgxResetNeeded},
+		{msg: "Pending does not wait for a reboot", statusCode: Pending},
+	}
+
+	for _, c := range cases {
+		assert.Equal(t, c.wantedRebootNeeded, c.statusCode.RebootNeeded(), c.msg)
+	}
+}
+
+func TestStatusCodeName(t *testing.T) {
+	cases := []struct {
+		msg        string
+		statusCode StatusCode
+		wantedName string
+	}{
+		{msg: "Pending", statusCode: Pending, wantedName: "Pending"},
+		{msg: "PlatformRebootNeeded", statusCode: PlatformRebootNeeded, wantedName: "PlatformRebootNeeded"},
+		{msg: "SgxResetNeeded", statusCode: SgxResetNeeded, wantedName: "SgxResetNeeded"},
+		{msg: "UnknownError", statusCode: UnknownError, wantedName: "UnknownError"},
+		{msg: "undefined status code", statusCode: StatusCode(42), wantedName: "UnknownError"},
+	}
+
+	for _, c := range cases {
+		assert.Equal(t, c.wantedName, c.statusCode.Name(), c.msg)
+	}
+}
+
 func TestUpdateServiceStatusCodeMetricWarning(t *testing.T) {
 
 	observedZapCore, observedLogs := observer.New(zap.InfoLevel)
@@ -0,0 +1,98 @@
+package nodestatus
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/opensovereigncloud/cc-intel-platform-registration/pkg/metrics"
+	"github.com/opensovereigncloud/cc-intel-platform-registration/pkg/registration"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes"
+)
+
+// Prefix of the labels and annotations set on the node
+const Prefix = "sgx.opensovereigncloud.io/"
+
+const (
+	// StatusLabel holds the status class of the node (registered, reboot-needed or failed), e.g. for the node
+	// selectors and affinities of the SGX workloads
+	StatusLabel = Prefix + "registration"
+	// StatusCodeAnnotation holds the status code of the last check, e.g. 09
+	StatusCodeAnnotation = Prefix + "registration-status-code"
+	// IntelErrorAnnotation holds the Intel error code of the last check; removed when the check has none
+	IntelErrorAnnotation = Prefix + "intel-error"
+	// FmspcAnnotation holds the FMSPC of the platform, kept from the last check decoding it from the PCK certificate
+	FmspcAnnotation = Prefix + "fmspc"
+	// LastCheckAnnotation holds the completion time of the last check, in RFC 3339 format
+	LastCheckAnnotation = Prefix + "last-check"
+)
+
+// Status classes of the StatusLabel
+const (
+	ClassRegistered   = "registered"
+	ClassRebootNeeded = "reboot-needed"
+	ClassFailed       = "failed"
+)
+
+// FieldManager identifies the agent in the managed fields of the node
+const FieldManager = "cc-intel-platform-registration"
+
+// StatusClass returns the status class of a registration status
+func StatusClass(status metrics.StatusCode) string {
+	switch {
+	case status == metrics.PlatformDirectlyRegistered:
+		return ClassRegistered
+	case status.RebootNeeded():
+		return ClassRebootNeeded
+	default:
+		return ClassFailed
+	}
+}
+
+// Publisher publishes the registration status on the labels and annotations of the node running the agent
+type Publisher struct {
+	client   kubernetes.Interface
+	nodeName string
+}
+
+// NewPublisher creates a publisher patching the given node
+func NewPublisher(client kubernetes.Interface, nodeName string) *Publisher {
+	return &Publisher{client: client, nodeName: nodeName}
+}
+
+// Publish patches the labels and annotations of the node with the result of a registration check.
+// Only the labels and annotations of the agent are changed.
+func (p *Publisher) Publish(ctx context.Context, checkResult registration.CheckResult) error {
+	annotations := map[string]any{
+		StatusCodeAnnotation: fmt.Sprintf("%02d", int(checkResult.Status)),
+		LastCheckAnnotation:  checkResult.CheckedAt.UTC().Format(time.RFC3339),
+		// a null value removes the error of a previous check from the merge patch
+		IntelErrorAnnotation: nil,
+	}
+	if checkResult.IntelError != "" {
+		annotations[IntelErrorAnnotation] = checkResult.IntelError
+	}
+	// the FMSPC is decoded from the PCK certificate, as a caching service may omit the FMSPC header of its response
+	if pckCertificates := checkResult.PckCertificates; pckCertificates != nil && pckCertificates.Extensions != nil &&
+		pckCertificates.Extensions.Fmspc != "" {
+		annotations[FmspcAnnotation] = pckCertificates.Extensions.Fmspc
+	}
+	patch, err := json.Marshal(map[string]any{
+		"metadata": map[string]any{
+			"labels":      map[string]any{StatusLabel: StatusClass(checkResult.Status)},
+			"annotations": annotations,
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to encode the node patch: %w", err)
+	}
+
+	_, err = p.client.CoreV1().Nodes().Patch(ctx, p.nodeName, types.MergePatchType, patch, metav1.PatchOptions{FieldManager: FieldManager})
+	if err != nil {
+		return fmt.Errorf("failed to patch the node %s: %w", p.nodeName, err)
+	}
+	return nil
+}
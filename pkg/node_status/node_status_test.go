@@ -0,0 +1,113 @@
+package nodestatus
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/opensovereigncloud/cc-intel-platform-registration/pkg/metrics"
+	pckcerts "github.com/opensovereigncloud/cc-intel-platform-registration/pkg/pck_certs"
+	"github.com/opensovereigncloud/cc-intel-platform-registration/pkg/registration"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+const testNodeName = "sgx-node-1"
+
+func newTestNode() *corev1.Node {
+	return &corev1.Node{ObjectMeta: metav1.ObjectMeta{
+		Name:        testNodeName,
+		Labels:      map[string]string{"kubernetes.io/hostname": testNodeName},
+		Annotations: map[string]string{"node.alpha.kubernetes.io/ttl": "0"},
+	}}
+}
+
+func TestStatusClass(t *testing.T) {
+	cases := []struct {
+		msg         string
+		status      metrics.StatusCode
+		wantedClass string
+	}{
+		{msg: "registered", status: metrics.PlatformDirectlyRegistered, wantedClass: ClassRegistered},
+		{msg: "platform reboot needed", status: metrics.PlatformRebootNeeded, wantedClass: ClassRebootNeeded},
+		{msg: "add package reboot needed", status: metrics.AddPackageRebootNeeded, wantedClass: ClassRebootNeeded},
+		{msg: "TCB recovery reboot needed", status: metrics.TcbRecoveryRegistered, wantedClass: ClassRebootNeeded},
+		{msg: "SGX reset needed", status: metrics.SgxResetNeeded, wantedClass: ClassFailed},
+		{msg: "Intel request failed", status: metrics.IntelRegServiceRequestFailed, wantedClass: ClassFailed},
+	}
+
+	for _, c := range cases {
+		assert.Equal(t, c.wantedClass, StatusClass(c.status), c.msg)
+	}
+}
+
+func TestPublisherPublish(t *testing.T) {
+	checkedAt := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	client := fake.NewSimpleClientset(newTestNode())
+	publisher := NewPublisher(client, testNodeName)
+	getNode := func() *corev1.Node {
+		node, err := client.CoreV1().Nodes().Get(context.Background(), testNodeName, metav1.GetOptions{})
+		require.NoError(t, err)
+		return node
+	}
+
+	// a failed registration
+	require.NoError(t, publisher.Publish(context.Background(), registration.CheckResult{
+		StatusCodeMetric: metrics.StatusCodeMetric{Status: metrics.IntelRegServiceRequestFailed, HttpStatusCode: "400", IntelError: "InvalidRequestSyntax"},
+		CheckedAt:        checkedAt,
+	}))
+	node := getNode()
+	assert.Equal(t, map[string]string{
+		"kubernetes.io/hostname": testNodeName,
+		StatusLabel:              ClassFailed,
+	}, node.Labels)
+	assert.Equal(t, map[string]string{
+		"node.alpha.kubernetes.io/ttl": "0",
+		StatusCodeAnnotation:           "12",
+		IntelErrorAnnotation:           "InvalidRequestSyntax",
+		LastCheckAnnotation:            "2025-03-01T12:00:00Z",
+	}, node.Annotations)
+
+	// the registered platform clears the error and publishes its FMSPC, decoded from the PCK certificate even
+	// when the response of a caching service has no FMSPC header
+	require.NoError(t, publisher.Publish(context.Background(), registration.CheckResult{
+		StatusCodeMetric: metrics.StatusCodeMetric{Status: metrics.PlatformDirectlyRegistered},
+		PckCertificates:  &pckcerts.PckCertificates{Extensions: &pckcerts.SgxExtensions{Fmspc: "00906ED50000"}},
+		CheckedAt:        checkedAt.Add(time.Hour),
+	}))
+	node = getNode()
+	assert.Equal(t, ClassRegistered, node.Labels[StatusLabel])
+	assert.Equal(t, map[string]string{
+		"node.alpha.kubernetes.io/ttl": "0",
+		StatusCodeAnnotation:           "09",
+		FmspcAnnotation:                "00906ED50000",
+		LastCheckAnnotation:            "2025-03-01T13:00:00Z",
+	}, node.Annotations)
+
+	// the FMSPC is kept by the checks without PCK certificates or decoded FMSPC
+	require.NoError(t, publisher.Publish(context.Background(), registration.CheckResult{
+		StatusCodeMetric: metrics.StatusCodeMetric{Status: metrics.TcbRecoveryRegistered},
+		CheckedAt:        checkedAt.Add(2 * time.Hour),
+	}))
+	require.NoError(t, publisher.Publish(context.Background(), registration.CheckResult{
+		StatusCodeMetric: metrics.StatusCodeMetric{Status: metrics.TcbRecoveryRegistered},
+		PckCertificates:  &pckcerts.PckCertificates{Fmspc: "00906ED50001"},
+		CheckedAt:        checkedAt.Add(3 * time.Hour),
+	}))
+	node = getNode()
+	assert.Equal(t, ClassRebootNeeded, node.Labels[StatusLabel])
+	assert.Equal(t, "07", node.Annotations[StatusCodeAnnotation])
+	assert.Equal(t, "00906ED50000", node.Annotations[FmspcAnnotation])
+}
+
+func TestPublisherPublishUnknownNode(t *testing.T) {
+	publisher := NewPublisher(fake.NewSimpleClientset(), testNodeName)
+	err := publisher.Publish(context.Background(), registration.CheckResult{
+		StatusCodeMetric: metrics.StatusCodeMetric{Status: metrics.PlatformDirectlyRegistered},
+		CheckedAt:        time.Now(),
+	})
+	assert.ErrorContains(t, err, testNodeName)
+}
@@ -0,0 +1,99 @@
+package oneshot
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/opensovereigncloud/cc-intel-platform-registration/pkg/metrics"
+	"github.com/opensovereigncloud/cc-intel-platform-registration/pkg/registration"
+)
+
+// Exit codes of the one-shot check; the exit code 1 is left to the errors preventing the check, e.g. an invalid configuration
+const (
+	// ExitRegistered is returned when the platform is registered (09)
+	ExitRegistered = 0
+	// ExitRebootNeeded is returned when the platform waits for a reboot to complete its registration (05, 06 or 07)
+	ExitRebootNeeded = 2
+	// ExitFailed is returned for every other status, including a check interrupted or timed out (98)
+	ExitFailed = 3
+)
+
+// Format is the output format of the check summary
+type Format string
+
+const (
+	FormatText Format = "text"
+	FormatJSON Format = "json"
+)
+
+// ParseFormat validates an output format
+func ParseFormat(format string) (Format, error) {
+	switch Format(format) {
+	case FormatText, FormatJSON:
+		return Format(format), nil
+	default:
+		return "", fmt.Errorf("unsupported output format %q, expected %q or %q", format, FormatText, FormatJSON)
+	}
+}
+
+// Checker runs a registration check, as implemented by registration.RegistrationService
+type Checker interface {
+	CheckRegistrationStatus(ctx context.Context) registration.CheckResult
+}
+
+// ExitCode maps the status of a registration check to the exit code of the one-shot check
+func ExitCode(status metrics.StatusCode) int {
+	switch {
+	case status == metrics.PlatformDirectlyRegistered:
+		return ExitRegistered
+	case status.RebootNeeded():
+		return ExitRebootNeeded
+	default:
+		return ExitFailed
+	}
+}
+
+// Run runs a single registration check, writes its summary to w and returns the exit code of its status
+func Run(ctx context.Context, checker Checker, w io.Writer, format Format) (int, error) {
+	checkResult := checker.CheckRegistrationStatus(ctx)
+	if err := writeSummary(w, format, registration.NewCheckSummary(checkResult)); err != nil {
+		return ExitFailed, err
+	}
+	return ExitCode(checkResult.Status), nil
+}
+
+// writeSummary writes the summary of a registration check in the given format
+func writeSummary(w io.Writer, format Format, summary registration.CheckSummary) error {
+	if format == FormatJSON {
+		return json.NewEncoder(w).Encode(summary)
+	}
+
+	lines := []string{fmt.Sprintf("Status: %02d %s", summary.Status, summary.Description)}
+	if summary.HttpStatusCode != "" {
+		lines = append(lines, "HTTP status code: "+summary.HttpStatusCode)
+	}
+	if summary.IntelErrorCode != "" {
+		lines = append(lines, "Intel error code: "+summary.IntelErrorCode)
+	}
+	if len(summary.IntelRequestIDs) > 0 {
+		lines = append(lines, "Intel request IDs: "+strings.Join(summary.IntelRequestIDs, ", "))
+	}
+	if summary.TcbStatus != "" {
+		lines = append(lines, "TCB status: "+summary.TcbStatus)
+	}
+	if summary.RegistrationCompletedAt != nil {
+		lines = append(lines, "Registration completed at: "+summary.RegistrationCompletedAt.Format(time.RFC3339))
+	}
+	if len(summary.DryRunActions) > 0 {
+		lines = append(lines, "Dry run, skipped actions:")
+		for _, action := range summary.DryRunActions {
+			lines = append(lines, "  - "+action)
+		}
+	}
+	_, err := fmt.Fprintln(w, strings.Join(lines, "\n"))
+	return err
+}
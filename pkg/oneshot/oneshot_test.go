@@ -0,0 +1,164 @@
+package oneshot
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	intelservices "github.com/opensovereigncloud/cc-intel-platform-registration/pkg/intel_services"
+	"github.com/opensovereigncloud/cc-intel-platform-registration/pkg/metrics"
+	"github.com/opensovereigncloud/cc-intel-platform-registration/pkg/registration"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeChecker struct {
+	checkResult registration.CheckResult
+	calls       int
+}
+
+func (f *fakeChecker) CheckRegistrationStatus(_ context.Context) registration.CheckResult {
+	f.calls++
+	return f.checkResult
+}
+
+type failingWriter struct{}
+
+func (failingWriter) Write(_ []byte) (int, error) {
+	return 0, errors.New("broken pipe")
+}
+
+func TestExitCode(t *testing.T) {
+	cases := []struct {
+		msg              string
+		status           metrics.StatusCode
+		expectedExitCode int
+	}{
+		{msg: "registered", status: metrics.PlatformDirectlyRegistered, expectedExitCode: ExitRegistered},
+		{msg: "platform reboot needed", status: metrics.PlatformRebootNeeded, expectedExitCode: ExitRebootNeeded},
+		{msg: "add package reboot needed", status: metrics.AddPackageRebootNeeded, expectedExitCode: ExitRebootNeeded},
+		{msg: "TCB recovery reboot needed", status: metrics.TcbRecoveryRegistered, expectedExitCode: ExitRebootNeeded},
+		{msg: "Intel unreachable", status: metrics.IntelConnectFailed, expectedExitCode: ExitFailed},
+		{msg: "revoked platform", status: metrics.PlatformRevoked, expectedExitCode: ExitFailed},
+		{msg: "canceled check", status: metrics.CheckCanceled, expectedExitCode: ExitFailed},
+		{msg: "unknown error", status: metrics.UnknownError, expectedExitCode: ExitFailed},
+	}
+
+	for _, c := range cases {
+		assert.Equal(t, c.expectedExitCode, ExitCode(c.status), c.msg)
+	}
+}
+
+func TestParseFormat(t *testing.T) {
+	cases := []struct {
+		msg            string
+		format         string
+		expectedFormat Format
+		expectError    bool
+	}{
+		{msg: "text", format: "text", expectedFormat: FormatText},
+		{msg: "json", format: "json", expectedFormat: FormatJSON},
+		{msg: "unsupported format", format: "yaml", expectError: true},
+		{msg: "empty format", format: "", expectError: true},
+	}
+
+	for _, c := range cases {
+		format, err := ParseFormat(c.format)
+		if c.expectError {
+			assert.Error(t, err, c.msg)
+			continue
+		}
+		assert.NoError(t, err, c.msg)
+		assert.Equal(t, c.expectedFormat, format, c.msg)
+	}
+}
+
+func TestRun(t *testing.T) {
+	completedAt := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	rebootNeeded := registration.CheckResult{
+		StatusCodeMetric:        metrics.StatusCodeMetric{Status: metrics.PlatformRebootNeeded},
+		RegistrationCompletedAt: completedAt,
+		IntelResponses:          []*intelservices.IntelResponse{{RequestID: "registration-request-id"}},
+	}
+	intelFailure := registration.CheckResult{
+		StatusCodeMetric: metrics.StatusCodeMetric{Status: metrics.IntelRegServiceRequestFailed, HttpStatusCode: "500", IntelError: "InternalError"},
+	}
+	cases := []struct {
+		msg              string
+		checkResult      registration.CheckResult
+		format           Format
+		expectedExitCode int
+		expectedOutput   string
+	}{
+		{
+			msg:              "text summary of a pending reboot",
+			checkResult:      rebootNeeded,
+			format:           FormatText,
+			expectedExitCode: ExitRebootNeeded,
+			expectedOutput: "Status: 05 " + metrics.PlatformRebootNeeded.String() + "\n" +
+				"Intel request IDs: registration-request-id\n" +
+				"Registration completed at: 2025-03-01T12:00:00Z\n",
+		},
+		{
+			msg:              "text summary of a failure",
+			checkResult:      intelFailure,
+			format:           FormatText,
+			expectedExitCode: ExitFailed,
+			expectedOutput: "Status: 12 " + metrics.IntelRegServiceRequestFailed.String() + "\n" +
+				"HTTP status code: 500\n" +
+				"Intel error code: InternalError\n",
+		},
+		{
+			msg: "text summary of a dry run",
+			checkResult: registration.CheckResult{
+				StatusCodeMetric: metrics.StatusCodeMetric{Status: metrics.PlatformRebootNeeded},
+				DryRunActions:    []string{"POST the platform manifest", "set SgxRegistrationComplete"},
+			},
+			format:           FormatText,
+			expectedExitCode: ExitRebootNeeded,
+			expectedOutput: "Status: 05 " + metrics.PlatformRebootNeeded.String() + "\n" +
+				"Dry run, skipped actions:\n" +
+				"  - POST the platform manifest\n" +
+				"  - set SgxRegistrationComplete\n",
+		},
+	}
+
+	for _, c := range cases {
+		checker := &fakeChecker{checkResult: c.checkResult}
+		var output bytes.Buffer
+		exitCode, err := Run(context.Background(), checker, &output, c.format)
+		assert.NoError(t, err, c.msg)
+		assert.Equal(t, c.expectedExitCode, exitCode, c.msg)
+		assert.Equal(t, c.expectedOutput, output.String(), c.msg)
+		assert.Equal(t, 1, checker.calls, c.msg)
+	}
+}
+
+func TestRunJSONSummary(t *testing.T) {
+	checker := &fakeChecker{checkResult: registration.CheckResult{
+		StatusCodeMetric: metrics.StatusCodeMetric{Status: metrics.PlatformDirectlyRegistered},
+	}}
+	var output bytes.Buffer
+	exitCode, err := Run(context.Background(), checker, &output, FormatJSON)
+	require.NoError(t, err)
+	assert.Equal(t, ExitRegistered, exitCode)
+
+	var summary registration.CheckSummary
+	require.NoError(t, json.Unmarshal(output.Bytes(), &summary))
+	assert.Equal(t, registration.CheckSummary{
+		Status:      int(metrics.PlatformDirectlyRegistered),
+		Description: metrics.PlatformDirectlyRegistered.String(),
+	}, summary)
+}
+
+func TestRunFailsToWriteSummary(t *testing.T) {
+	checker := &fakeChecker{checkResult: registration.CheckResult{
+		StatusCodeMetric: metrics.StatusCodeMetric{Status: metrics.PlatformDirectlyRegistered},
+	}}
+	exitCode, err := Run(context.Background(), checker, failingWriter{}, FormatText)
+	assert.Error(t, err)
+	assert.Equal(t, ExitFailed, exitCode)
+}
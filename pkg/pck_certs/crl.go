@@ -0,0 +1,106 @@
+package pckcerts
+
+import (
+	"bytes"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// PckCrlIssuerChainHeader is the PCS response header carrying the PCK CRL issuer chain
+const PckCrlIssuerChainHeader = "SGX-PCK-CRL-Issuer-Chain"
+
+// PCK CA types, as used by the PCS pckcrl endpoint
+const (
+	CaTypeProcessor = "processor"
+	CaTypePlatform  = "platform"
+)
+
+// PckCrl is the decoded response of the PCS pckcrl endpoint
+type PckCrl struct {
+	// CaType is the type of the PCK CA issuing the CRL (processor or platform)
+	CaType         string
+	RevocationList *x509.RevocationList
+	// IssuerChain holds the PCK CA and root certificates
+	IssuerChain []*x509.Certificate
+}
+
+// CrlCaType returns the type of the PCK CA issuing the PCK certificates, as reported by the PCS or,
+// when the header is missing (e.g. PCS API v3), as deduced from the issuer chain
+func (p *PckCertificates) CrlCaType() (string, error) {
+	switch caType := strings.ToLower(p.CaType); caType {
+	case CaTypeProcessor, CaTypePlatform:
+		return caType, nil
+	}
+	for _, certificate := range p.IssuerChain {
+		switch {
+		case strings.Contains(certificate.Subject.CommonName, "Processor CA"):
+			return CaTypeProcessor, nil
+		case strings.Contains(certificate.Subject.CommonName, "Platform CA"):
+			return CaTypePlatform, nil
+		}
+	}
+	return "", fmt.Errorf("unable to determine the PCK CA type")
+}
+
+// ParseCrl decodes the body and the headers of a successful PCS pckcrl response. The CRL is accepted
+// PEM-encoded, DER-encoded or hex-encoded DER, as returned by the PCS and the PCCS API versions.
+func ParseCrl(caType string, body []byte, header http.Header) (*PckCrl, error) {
+	der := bytes.TrimSpace(body)
+	if block, _ := pem.Decode(der); block != nil {
+		der = block.Bytes
+	} else if decoded, err := hex.DecodeString(string(der)); err == nil {
+		der = decoded
+	}
+	revocationList, err := x509.ParseRevocationList(der)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode PCK CRL: %w", err)
+	}
+
+	issuerChain, err := ParseIssuerChainHeader(header.Get(PckCrlIssuerChainHeader))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode %s header: %w", PckCrlIssuerChainHeader, err)
+	}
+	return &PckCrl{CaType: caType, RevocationList: revocationList, IssuerChain: issuerChain}, nil
+}
+
+// VerifyCrl checks that the PCK CRL is signed by a PCK CA chaining up to the trusted root CA.
+// The returned error wraps ErrChainInvalid when the verification fails.
+func (v *Verifier) VerifyCrl(pckCrl *PckCrl) error {
+	issuer, err := v.VerifyIssuerChain(pckCrl.IssuerChain)
+	if err != nil {
+		return fmt.Errorf("PCK CRL: %w", err)
+	}
+	if err := pckCrl.RevocationList.CheckSignatureFrom(issuer); err != nil {
+		return fmt.Errorf("%w: PCK CRL signature: %w", ErrChainInvalid, err)
+	}
+	return nil
+}
+
+// Outdated reports whether the CRL is past its nextUpdate, e.g. served by a stale cache or kept after a failed
+// refresh, so the certificates revoked since then are missing from it
+func (c *PckCrl) Outdated(now time.Time) bool {
+	nextUpdate := c.RevocationList.NextUpdate
+	return !nextUpdate.IsZero() && !now.Before(nextUpdate)
+}
+
+// RevokedTcbms returns the TCBm of the available PCK certificates revoked by the CRL
+func (c *PckCrl) RevokedTcbms(pckCertificates *PckCertificates) []string {
+	var revokedTcbms []string
+	for _, pckCertificate := range pckCertificates.Certificates {
+		if pckCertificate.Certificate == nil || !bytes.Equal(pckCertificate.Certificate.RawIssuer, c.RevocationList.RawIssuer) {
+			continue
+		}
+		for _, entry := range c.RevocationList.RevokedCertificateEntries {
+			if entry.SerialNumber.Cmp(pckCertificate.Certificate.SerialNumber) == 0 {
+				revokedTcbms = append(revokedTcbms, pckCertificate.Tcbm)
+				break
+			}
+		}
+	}
+	return revokedTcbms
+}
@@ -0,0 +1,223 @@
+package pckcerts
+
+import (
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/hex"
+	"fmt"
+)
+
+// SGX extension OIDs, as defined by the SGX PCK Certificate and CRL Profile Specification
+var (
+	OidSgxExtensions        = asn1.ObjectIdentifier{1, 2, 840, 113741, 1, 13, 1}
+	OidPPID                 = asn1.ObjectIdentifier{1, 2, 840, 113741, 1, 13, 1, 1}
+	OidTcb                  = asn1.ObjectIdentifier{1, 2, 840, 113741, 1, 13, 1, 2}
+	OidPceSvn               = asn1.ObjectIdentifier{1, 2, 840, 113741, 1, 13, 1, 2, 17}
+	OidCpuSvn               = asn1.ObjectIdentifier{1, 2, 840, 113741, 1, 13, 1, 2, 18}
+	OidPceID                = asn1.ObjectIdentifier{1, 2, 840, 113741, 1, 13, 1, 3}
+	OidFmspc                = asn1.ObjectIdentifier{1, 2, 840, 113741, 1, 13, 1, 4}
+	OidSgxType              = asn1.ObjectIdentifier{1, 2, 840, 113741, 1, 13, 1, 5}
+	OidPlatformInstanceID   = asn1.ObjectIdentifier{1, 2, 840, 113741, 1, 13, 1, 6}
+	OidConfiguration        = asn1.ObjectIdentifier{1, 2, 840, 113741, 1, 13, 1, 7}
+	OidDynamicPlatform      = asn1.ObjectIdentifier{1, 2, 840, 113741, 1, 13, 1, 7, 1}
+	OidCachedKeys           = asn1.ObjectIdentifier{1, 2, 840, 113741, 1, 13, 1, 7, 2}
+	OidSmtEnabled           = asn1.ObjectIdentifier{1, 2, 840, 113741, 1, 13, 1, 7, 3}
+	cpuSvnSize              = 16
+	ppidSize                = 16
+	pceIDSize               = 2
+	fmspcSize               = 6
+	platformInstanceIDSize  = 16
+	sgxTcbComponentsOidBase = len(OidTcb)
+)
+
+// SgxType is the SGX type of the platform
+type SgxType int
+
+const (
+	SgxTypeStandard              SgxType = 0
+	SgxTypeScalable              SgxType = 1
+	SgxTypeScalableWithIntegrity SgxType = 2
+)
+
+func (t SgxType) String() string {
+	switch t {
+	case SgxTypeStandard:
+		return "Standard"
+	case SgxTypeScalable:
+		return "Scalable"
+	case SgxTypeScalableWithIntegrity:
+		return "ScalableWithIntegrity"
+	default:
+		return "Unknown"
+	}
+}
+
+// Configuration holds the platform configuration, only present in the certificates issued by the PCK Platform CA
+type Configuration struct {
+	DynamicPlatform bool
+	// CachedKeys is set when the Intel RS caches the platform root keys, i.e. the platform was directly registered
+	CachedKeys bool
+	SmtEnabled bool
+}
+
+// SgxExtensions is the decoded SGX extension of a PCK certificate
+type SgxExtensions struct {
+	// PPID is the hex-encoded Platform Provisioning ID
+	PPID   string
+	Tcb    Tcb
+	CpuSvn [16]byte
+	// PceID is the hex-encoded PCE identifier
+	PceID string
+	// Fmspc is the hex-encoded Family-Model-Stepping-Platform-CustomSKU
+	Fmspc   string
+	SgxType SgxType
+	// PlatformInstanceID is the hex-encoded platform instance ID, only set for the PCK Platform CA certificates
+	PlatformInstanceID string
+	// Configuration is nil for the certificates issued by the PCK Processor CA
+	Configuration *Configuration
+}
+
+type sgxExtensionEntry struct {
+	ID    asn1.ObjectIdentifier
+	Value asn1.RawValue
+}
+
+// ParseSgxExtensions decodes the SGX extension of a PCK certificate
+func ParseSgxExtensions(certificate *x509.Certificate) (*SgxExtensions, error) {
+	for _, extension := range certificate.Extensions {
+		if extension.Id.Equal(OidSgxExtensions) {
+			return parseSgxExtensionsValue(extension.Value)
+		}
+	}
+	return nil, fmt.Errorf("certificate has no SGX extension")
+}
+
+func parseSgxExtensionsValue(value []byte) (*SgxExtensions, error) {
+	entries, err := parseSgxExtensionEntries(value)
+	if err != nil {
+		return nil, fmt.Errorf("invalid SGX extension: %w", err)
+	}
+
+	extensions := &SgxExtensions{}
+	found := map[string]bool{}
+	for _, entry := range entries {
+		found[entry.ID.String()] = true
+		switch {
+		case entry.ID.Equal(OidPPID):
+			extensions.PPID, err = parseOctetString(entry.Value, ppidSize)
+		case entry.ID.Equal(OidTcb):
+			err = parseTcb(entry.Value, extensions)
+		case entry.ID.Equal(OidPceID):
+			extensions.PceID, err = parseOctetString(entry.Value, pceIDSize)
+		case entry.ID.Equal(OidFmspc):
+			extensions.Fmspc, err = parseOctetString(entry.Value, fmspcSize)
+		case entry.ID.Equal(OidSgxType):
+			var sgxType asn1.Enumerated
+			_, err = asn1.Unmarshal(entry.Value.FullBytes, &sgxType)
+			extensions.SgxType = SgxType(sgxType)
+		case entry.ID.Equal(OidPlatformInstanceID):
+			extensions.PlatformInstanceID, err = parseOctetString(entry.Value, platformInstanceIDSize)
+		case entry.ID.Equal(OidConfiguration):
+			extensions.Configuration, err = parseConfiguration(entry.Value)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid SGX extension %s: %w", entry.ID, err)
+		}
+	}
+
+	for _, mandatory := range []asn1.ObjectIdentifier{OidPPID, OidTcb, OidPceID, OidFmspc, OidSgxType} {
+		if !found[mandatory.String()] {
+			return nil, fmt.Errorf("SGX extension %s is missing", mandatory)
+		}
+	}
+	return extensions, nil
+}
+
+func parseSgxExtensionEntries(value []byte) ([]sgxExtensionEntry, error) {
+	var entries []sgxExtensionEntry
+	rest, err := asn1.Unmarshal(value, &entries)
+	if err != nil {
+		return nil, err
+	}
+	if len(rest) != 0 {
+		return nil, fmt.Errorf("trailing data after SGX extension")
+	}
+	return entries, nil
+}
+
+func parseOctetString(value asn1.RawValue, size int) (string, error) {
+	var octets []byte
+	if _, err := asn1.Unmarshal(value.FullBytes, &octets); err != nil {
+		return "", err
+	}
+	if len(octets) != size {
+		return "", fmt.Errorf("expected %d bytes, got %d", size, len(octets))
+	}
+	return hex.EncodeToString(octets), nil
+}
+
+func parseTcb(value asn1.RawValue, extensions *SgxExtensions) error {
+	entries, err := parseSgxExtensionEntries(value.FullBytes)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		switch {
+		case entry.ID.Equal(OidPceSvn):
+			var pceSvn int
+			if _, err := asn1.Unmarshal(entry.Value.FullBytes, &pceSvn); err != nil {
+				return fmt.Errorf("invalid PCESVN: %w", err)
+			}
+			extensions.Tcb.PceSvn = uint16(pceSvn)
+		case entry.ID.Equal(OidCpuSvn):
+			var cpuSvn []byte
+			if _, err := asn1.Unmarshal(entry.Value.FullBytes, &cpuSvn); err != nil {
+				return fmt.Errorf("invalid CPUSVN: %w", err)
+			}
+			if len(cpuSvn) != cpuSvnSize {
+				return fmt.Errorf("expected %d CPUSVN bytes, got %d", cpuSvnSize, len(cpuSvn))
+			}
+			copy(extensions.CpuSvn[:], cpuSvn)
+		case len(entry.ID) == sgxTcbComponentsOidBase+1 && entry.ID[:sgxTcbComponentsOidBase].Equal(OidTcb):
+			component := entry.ID[sgxTcbComponentsOidBase]
+			if component < 1 || component > SgxTcbComponentsCount {
+				continue
+			}
+			var svn int
+			if _, err := asn1.Unmarshal(entry.Value.FullBytes, &svn); err != nil {
+				return fmt.Errorf("invalid TCB component %d: %w", component, err)
+			}
+			extensions.Tcb.SgxTcbComponents[component-1] = uint8(svn)
+		}
+	}
+	return nil
+}
+
+func parseConfiguration(value asn1.RawValue) (*Configuration, error) {
+	entries, err := parseSgxExtensionEntries(value.FullBytes)
+	if err != nil {
+		return nil, err
+	}
+	configuration := &Configuration{}
+	for _, entry := range entries {
+		var flag bool
+		if _, err := asn1.Unmarshal(entry.Value.FullBytes, &flag); err != nil {
+			return nil, fmt.Errorf("invalid configuration flag %s: %w", entry.ID, err)
+		}
+		switch {
+		case entry.ID.Equal(OidDynamicPlatform):
+			configuration.DynamicPlatform = flag
+		case entry.ID.Equal(OidCachedKeys):
+			configuration.CachedKeys = flag
+		case entry.ID.Equal(OidSmtEnabled):
+			configuration.SmtEnabled = flag
+		}
+	}
+	return configuration, nil
+}
+
+// IsDirectlyRegistered reports whether the Intel RS caches the platform root keys. Platforms whose certificates
+// are issued by the PCK Processor CA carry no configuration and have no platform keys to cache.
+func (e *SgxExtensions) IsDirectlyRegistered() bool {
+	return e.Configuration == nil || e.Configuration.CachedKeys
+}
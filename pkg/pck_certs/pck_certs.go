@@ -0,0 +1,228 @@
+package pckcerts
+
+import (
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// PCS response headers
+const (
+	PckCertificateIssuerChainHeader = "SGX-PCK-Certificate-Issuer-Chain"
+	FmspcHeader                     = "SGX-FMSPC"
+	PckCertificateCaTypeHeader      = "SGX-PCK-Certificate-CA-Type"
+)
+
+// SgxTcbComponentsCount is the number of SGX TCB components of a TCB level
+const SgxTcbComponentsCount = 16
+
+// certNotAvailable is the value of the cert field of a TCB level with no PCK certificate
+const certNotAvailable = "Not available"
+
+// Tcb is a TCB level: the SVNs of the SGX TCB components and the PCE SVN
+type Tcb struct {
+	SgxTcbComponents [SgxTcbComponentsCount]uint8
+	PceSvn           uint16
+}
+
+// UnmarshalJSON decodes both the PCS v4 representation of a TCB level ("sgxtcbcomponents" array)
+// and the PCS v3 one ("sgxtcbcompXXsvn" fields)
+func (t *Tcb) UnmarshalJSON(data []byte) error {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return err
+	}
+
+	pceSvn, ok := fields["pcesvn"]
+	if !ok {
+		return fmt.Errorf("TCB level has no pcesvn")
+	}
+	if err := json.Unmarshal(pceSvn, &t.PceSvn); err != nil {
+		return fmt.Errorf("invalid pcesvn: %w", err)
+	}
+
+	if rawComponents, ok := fields["sgxtcbcomponents"]; ok {
+		var components []struct {
+			Svn uint8 `json:"svn"`
+		}
+		if err := json.Unmarshal(rawComponents, &components); err != nil {
+			return fmt.Errorf("invalid sgxtcbcomponents: %w", err)
+		}
+		if len(components) != SgxTcbComponentsCount {
+			return fmt.Errorf("expected %d sgxtcbcomponents, got %d", SgxTcbComponentsCount, len(components))
+		}
+		for i, component := range components {
+			t.SgxTcbComponents[i] = component.Svn
+		}
+		return nil
+	}
+
+	for i := range t.SgxTcbComponents {
+		name := fmt.Sprintf("sgxtcbcomp%02dsvn", i+1)
+		rawSvn, ok := fields[name]
+		if !ok {
+			return fmt.Errorf("TCB level has no %s", name)
+		}
+		if err := json.Unmarshal(rawSvn, &t.SgxTcbComponents[i]); err != nil {
+			return fmt.Errorf("invalid %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// PckCertificate is a PCK certificate issued for a TCB level of the platform
+type PckCertificate struct {
+	Tcb Tcb
+	// Tcbm is the hex-encoded CPUSVN (16 bytes) followed by the little-endian PCESVN (2 bytes)
+	Tcbm string
+	// Certificate is nil when the PCS has no certificate for this TCB level
+	Certificate *x509.Certificate
+}
+
+// PckCertificates is the decoded response of the PCS pckcerts endpoint
+type PckCertificates struct {
+	Certificates []PckCertificate
+	// IssuerChain holds the intermediate (PCK Platform or Processor CA) and root certificates
+	IssuerChain []*x509.Certificate
+	// Fmspc is the hex-encoded FMSPC of the platform, when reported by the PCS
+	Fmspc string
+	// CaType is the type of the issuing CA (processor or platform), when reported by the PCS
+	CaType string
+	// Extensions holds the SGX extension of the platform PCK certificates, once decoded by DecodeSgxExtensions
+	Extensions *SgxExtensions
+}
+
+type pckCertificateResponse struct {
+	Tcb  Tcb    `json:"tcb"`
+	Tcbm string `json:"tcbm"`
+	Cert string `json:"cert"`
+}
+
+// Parse decodes the body and the headers of a successful PCS pckcerts response
+func Parse(body []byte, header http.Header) (*PckCertificates, error) {
+	var responses []pckCertificateResponse
+	if err := json.Unmarshal(body, &responses); err != nil {
+		return nil, fmt.Errorf("failed to decode PCK certificates: %w", err)
+	}
+
+	pckCertificates := &PckCertificates{
+		Fmspc:  strings.ToLower(header.Get(FmspcHeader)),
+		CaType: header.Get(PckCertificateCaTypeHeader),
+	}
+
+	issuerChain, err := ParseIssuerChainHeader(header.Get(PckCertificateIssuerChainHeader))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode %s header: %w", PckCertificateIssuerChainHeader, err)
+	}
+	pckCertificates.IssuerChain = issuerChain
+
+	for _, response := range responses {
+		if _, err := hex.DecodeString(response.Tcbm); err != nil {
+			return nil, fmt.Errorf("invalid tcbm %q: %w", response.Tcbm, err)
+		}
+		pckCertificate := PckCertificate{
+			Tcb:  response.Tcb,
+			Tcbm: strings.ToLower(response.Tcbm),
+		}
+		if response.Cert != "" && response.Cert != certNotAvailable {
+			certificates, err := parsePemCertificates(response.Cert)
+			if err != nil {
+				return nil, fmt.Errorf("invalid PCK certificate for tcbm %s: %w", response.Tcbm, err)
+			}
+			if len(certificates) != 1 {
+				return nil, fmt.Errorf("expected one PCK certificate for tcbm %s, got %d", response.Tcbm, len(certificates))
+			}
+			pckCertificate.Certificate = certificates[0]
+		}
+		pckCertificates.Certificates = append(pckCertificates.Certificates, pckCertificate)
+	}
+
+	return pckCertificates, nil
+}
+
+// AvailableTcbms returns the TCBm of every TCB level for which the platform has a PCK certificate
+func (p *PckCertificates) AvailableTcbms() []string {
+	var tcbms []string
+	for _, pckCertificate := range p.Certificates {
+		if pckCertificate.Certificate != nil {
+			tcbms = append(tcbms, pckCertificate.Tcbm)
+		}
+	}
+	return tcbms
+}
+
+// DecodeSgxExtensions decodes the SGX extension of the available PCK certificates and stores it in Extensions.
+// All the certificates of a platform share the same platform properties (PPID, PCE-ID, FMSPC, SGX type and
+// configuration); only their TCB differ.
+func (p *PckCertificates) DecodeSgxExtensions() (*SgxExtensions, error) {
+	var platformExtensions *SgxExtensions
+	for _, pckCertificate := range p.Certificates {
+		if pckCertificate.Certificate == nil {
+			continue
+		}
+		extensions, err := ParseSgxExtensions(pckCertificate.Certificate)
+		if err != nil {
+			return nil, fmt.Errorf("PCK certificate for tcbm %s: %w", pckCertificate.Tcbm, err)
+		}
+		if platformExtensions == nil {
+			platformExtensions = extensions
+			continue
+		}
+		if extensions.PPID != platformExtensions.PPID || extensions.Fmspc != platformExtensions.Fmspc ||
+			extensions.PceID != platformExtensions.PceID || extensions.SgxType != platformExtensions.SgxType {
+			return nil, fmt.Errorf("PCK certificate for tcbm %s does not belong to the same platform", pckCertificate.Tcbm)
+		}
+	}
+	if platformExtensions == nil {
+		return nil, fmt.Errorf("no PCK certificate available")
+	}
+	p.Extensions = platformExtensions
+	return platformExtensions, nil
+}
+
+// ParseIssuerChainHeader decodes a URL-encoded PEM certificate chain as sent in the PCS response headers
+func ParseIssuerChainHeader(value string) ([]*x509.Certificate, error) {
+	if value == "" {
+		return nil, nil
+	}
+	return parsePemCertificates(value)
+}
+
+// parsePemCertificates decodes PEM certificates, which may be URL-encoded
+func parsePemCertificates(data string) ([]*x509.Certificate, error) {
+	if strings.Contains(data, "%") {
+		// path unescaping keeps the '+' characters of the base64 content
+		decoded, err := url.PathUnescape(data)
+		if err != nil {
+			return nil, err
+		}
+		data = decoded
+	}
+
+	var certificates []*x509.Certificate
+	rest := []byte(data)
+	for {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		certificate, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		certificates = append(certificates, certificate)
+	}
+	if len(certificates) == 0 {
+		return nil, fmt.Errorf("no PEM certificate found")
+	}
+	return certificates, nil
+}
@@ -0,0 +1,635 @@
+package pckcerts
+
+import (
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	testcerts "github.com/opensovereigncloud/cc-intel-platform-registration/internal/pkg/test_certs"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testChain struct {
+	root         testcerts.Certificate
+	intermediate testcerts.Certificate
+	leaf         testcerts.Certificate
+}
+
+func newTestChain(t testing.TB, extensions ...pkix.Extension) testChain {
+	t.Helper()
+	notAfter := time.Now().Add(24 * time.Hour)
+	root := testcerts.New(t, "Intel SGX Root CA", nil, true, notAfter)
+	intermediate := testcerts.New(t, "Intel SGX PCK Platform CA", &root, true, notAfter)
+	leaf := testcerts.New(t, "Intel SGX PCK Certificate", &intermediate, false, notAfter, extensions...)
+	return testChain{root: root, intermediate: intermediate, leaf: leaf}
+}
+
+func (c testChain) issuerChainHeader() string {
+	return url.PathEscape(c.intermediate.PEM() + c.root.PEM())
+}
+
+func newPckCertsBody(t testing.TB, certs ...string) []byte {
+	t.Helper()
+	var responses []map[string]any
+	for i, cert := range certs {
+		components := make([]map[string]int, SgxTcbComponentsCount)
+		for j := range components {
+			components[j] = map[string]int{"svn": i}
+		}
+		responses = append(responses, map[string]any{
+			"tcb":  map[string]any{"sgxtcbcomponents": components, "pcesvn": 13},
+			"tcbm": strings.Repeat("0", 31) + string(rune('0'+i)) + "0d00",
+			"cert": cert,
+		})
+	}
+	body, err := json.Marshal(responses)
+	require.NoError(t, err)
+	return body
+}
+
+func TestParse(t *testing.T) {
+	chain := newTestChain(t)
+	header := http.Header{}
+	header.Set(PckCertificateIssuerChainHeader, chain.issuerChainHeader())
+	header.Set(FmspcHeader, "00906ED50000")
+	header.Set(PckCertificateCaTypeHeader, "platform")
+
+	pckCertificates, err := Parse(newPckCertsBody(t, chain.leaf.PEM(), certNotAvailable), header)
+	require.NoError(t, err)
+
+	assert.Equal(t, "00906ed50000", pckCertificates.Fmspc)
+	assert.Equal(t, "platform", pckCertificates.CaType)
+	if assert.Len(t, pckCertificates.IssuerChain, 2) {
+		assert.Equal(t, chain.intermediate.Certificate.Raw, pckCertificates.IssuerChain[0].Raw)
+		assert.Equal(t, chain.root.Certificate.Raw, pckCertificates.IssuerChain[1].Raw)
+	}
+	if assert.Len(t, pckCertificates.Certificates, 2) {
+		assert.Equal(t, chain.leaf.Certificate.Raw, pckCertificates.Certificates[0].Certificate.Raw)
+		assert.Equal(t, uint16(13), pckCertificates.Certificates[0].Tcb.PceSvn)
+		assert.Equal(t, uint8(1), pckCertificates.Certificates[1].Tcb.SgxTcbComponents[15])
+		assert.Nil(t, pckCertificates.Certificates[1].Certificate)
+	}
+	assert.Equal(t, []string{strings.Repeat("0", 32) + "0d00"}, pckCertificates.AvailableTcbms())
+}
+
+func TestParseErrors(t *testing.T) {
+	chain := newTestChain(t)
+	cases := []struct {
+		msg         string
+		body        string
+		issuerChain string
+	}{
+		{
+			msg:  "body is not a JSON array",
+			body: `{"tcb":{}}`,
+		},
+		{
+			msg:  "tcbm is not hex encoded",
+			body: `[{"tcb":{"sgxtcbcomponents":[{"svn":0},{"svn":0},{"svn":0},{"svn":0},{"svn":0},{"svn":0},{"svn":0},{"svn":0},{"svn":0},{"svn":0},{"svn":0},{"svn":0},{"svn":0},{"svn":0},{"svn":0},{"svn":0}],"pcesvn":13},"tcbm":"xyz","cert":"Not available"}]`,
+		},
+		{
+			msg:  "TCB level has too few components",
+			body: `[{"tcb":{"sgxtcbcomponents":[{"svn":0}],"pcesvn":13},"tcbm":"00","cert":"Not available"}]`,
+		},
+		{
+			msg:  "certificate is not PEM encoded",
+			body: `[{"tcb":{"sgxtcbcomponents":[{"svn":0},{"svn":0},{"svn":0},{"svn":0},{"svn":0},{"svn":0},{"svn":0},{"svn":0},{"svn":0},{"svn":0},{"svn":0},{"svn":0},{"svn":0},{"svn":0},{"svn":0},{"svn":0}],"pcesvn":13},"tcbm":"00","cert":"garbage"}]`,
+		},
+		{
+			msg:         "issuer chain is not PEM encoded",
+			body:        string(newPckCertsBody(t, chain.leaf.PEM())),
+			issuerChain: "garbage",
+		},
+	}
+
+	for _, c := range cases {
+		header := http.Header{}
+		header.Set(PckCertificateIssuerChainHeader, c.issuerChain)
+		_, err := Parse([]byte(c.body), header)
+		assert.Error(t, err, c.msg)
+	}
+}
+
+func TestTcbUnmarshalJSONv3(t *testing.T) {
+	var tcb Tcb
+	err := json.Unmarshal([]byte(`{"sgxtcbcomp01svn":4,"sgxtcbcomp02svn":4,"sgxtcbcomp03svn":3,"sgxtcbcomp04svn":3,
+		"sgxtcbcomp05svn":255,"sgxtcbcomp06svn":255,"sgxtcbcomp07svn":0,"sgxtcbcomp08svn":0,"sgxtcbcomp09svn":0,
+		"sgxtcbcomp10svn":0,"sgxtcbcomp11svn":0,"sgxtcbcomp12svn":0,"sgxtcbcomp13svn":0,"sgxtcbcomp14svn":0,
+		"sgxtcbcomp15svn":0,"sgxtcbcomp16svn":7,"pcesvn":11}`), &tcb)
+	require.NoError(t, err)
+	assert.Equal(t, uint16(11), tcb.PceSvn)
+	assert.Equal(t, uint8(4), tcb.SgxTcbComponents[0])
+	assert.Equal(t, uint8(255), tcb.SgxTcbComponents[5])
+	assert.Equal(t, uint8(7), tcb.SgxTcbComponents[15])
+
+	err = json.Unmarshal([]byte(`{"sgxtcbcomp01svn":4,"pcesvn":11}`), &tcb)
+	assert.Error(t, err)
+}
+
+func TestIntelSgxRootCA(t *testing.T) {
+	rootCA := IntelSgxRootCA()
+	assert.Equal(t, "Intel SGX Root CA", rootCA.Subject.CommonName)
+	assert.True(t, rootCA.IsCA)
+	assert.Equal(t, "44a0196b2b99f889b8e149e95b807a350e7424964399e885a7cbb8ccfab674d3", Fingerprint(rootCA))
+}
+
+func TestLoadRootCA(t *testing.T) {
+	chain := newTestChain(t)
+	dir := t.TempDir()
+
+	rootPath := dir + "/root.pem"
+	require.NoError(t, os.WriteFile(rootPath, []byte(chain.root.PEM()), 0o600))
+	rootCA, err := LoadRootCA(rootPath)
+	require.NoError(t, err)
+	assert.Equal(t, chain.root.Certificate.Raw, rootCA.Raw)
+
+	leafPath := dir + "/leaf.pem"
+	require.NoError(t, os.WriteFile(leafPath, []byte(chain.leaf.PEM()), 0o600))
+	_, err = LoadRootCA(leafPath)
+	assert.Error(t, err, "a leaf certificate is not a root CA")
+
+	_, err = LoadRootCA(dir + "/missing.pem")
+	assert.Error(t, err, "a missing file cannot be loaded")
+}
+
+func TestVerify(t *testing.T) {
+	chain := newTestChain(t)
+	otherChain := newTestChain(t)
+	expiredLeaf := testcerts.New(t, "Intel SGX PCK Certificate", &chain.intermediate, false, time.Now().Add(-time.Minute))
+
+	cases := []struct {
+		msg         string
+		rootCA      *x509.Certificate
+		issuerChain []*x509.Certificate
+		leaves      []*x509.Certificate
+		expectError bool
+	}{
+		{
+			msg:         "PCK certificate chaining up to the root CA is valid",
+			rootCA:      chain.root.Certificate,
+			issuerChain: []*x509.Certificate{chain.intermediate.Certificate, chain.root.Certificate},
+			leaves:      []*x509.Certificate{chain.leaf.Certificate, nil},
+		},
+		{
+			msg:         "issuer chain without the root is valid",
+			rootCA:      chain.root.Certificate,
+			issuerChain: []*x509.Certificate{chain.intermediate.Certificate},
+			leaves:      []*x509.Certificate{chain.leaf.Certificate},
+		},
+		{
+			msg:         "PCK certificate chaining up to another root CA is invalid",
+			rootCA:      otherChain.root.Certificate,
+			issuerChain: []*x509.Certificate{chain.intermediate.Certificate, chain.root.Certificate},
+			leaves:      []*x509.Certificate{chain.leaf.Certificate},
+			expectError: true,
+		},
+		{
+			msg:         "PCK certificate issued by a foreign intermediate is invalid",
+			rootCA:      chain.root.Certificate,
+			issuerChain: []*x509.Certificate{chain.intermediate.Certificate, chain.root.Certificate},
+			leaves:      []*x509.Certificate{chain.leaf.Certificate, otherChain.leaf.Certificate},
+			expectError: true,
+		},
+		{
+			msg:         "expired PCK certificate is invalid",
+			rootCA:      chain.root.Certificate,
+			issuerChain: []*x509.Certificate{chain.intermediate.Certificate},
+			leaves:      []*x509.Certificate{expiredLeaf.Certificate},
+			expectError: true,
+		},
+		{
+			msg:         "missing issuer chain is invalid",
+			rootCA:      chain.root.Certificate,
+			leaves:      []*x509.Certificate{chain.leaf.Certificate},
+			expectError: true,
+		},
+		{
+			msg:         "no available PCK certificate is invalid",
+			rootCA:      chain.root.Certificate,
+			issuerChain: []*x509.Certificate{chain.intermediate.Certificate},
+			leaves:      []*x509.Certificate{nil},
+			expectError: true,
+		},
+	}
+
+	for _, c := range cases {
+		pckCertificates := &PckCertificates{IssuerChain: c.issuerChain}
+		for _, leaf := range c.leaves {
+			pckCertificates.Certificates = append(pckCertificates.Certificates, PckCertificate{Certificate: leaf})
+		}
+		err := NewVerifier(c.rootCA).Verify(pckCertificates)
+		if c.expectError {
+			assert.ErrorIs(t, err, ErrChainInvalid, c.msg)
+		} else {
+			assert.NoError(t, err, c.msg)
+		}
+	}
+}
+
+type testSgxExtensionEntry struct {
+	ID    asn1.ObjectIdentifier
+	Value asn1.RawValue
+}
+
+func newTestAsn1Value(t testing.TB, value any) asn1.RawValue {
+	t.Helper()
+	der, err := asn1.Marshal(value)
+	require.NoError(t, err)
+	return asn1.RawValue{FullBytes: der}
+}
+
+// newTestSgxExtensionEntries returns the entries of the SGX extension of a PCK certificate with TCB 03030202ff01...,
+// PCESVN 13, FMSPC 00906ed50000 and PCE-ID 0000; the configuration is omitted when nil, as for the PCK Processor CA
+func newTestSgxExtensionEntries(t testing.TB, sgxType SgxType, configuration *Configuration) []testSgxExtensionEntry {
+	t.Helper()
+	svns := []int{3, 3, 2, 2, 255, 1, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0}
+	var tcb []testSgxExtensionEntry
+	for i, svn := range svns {
+		tcb = append(tcb, testSgxExtensionEntry{ID: append(append(asn1.ObjectIdentifier{}, OidTcb...), i+1), Value: newTestAsn1Value(t, svn)})
+	}
+	tcb = append(tcb,
+		testSgxExtensionEntry{ID: OidPceSvn, Value: newTestAsn1Value(t, 13)},
+		testSgxExtensionEntry{ID: OidCpuSvn, Value: newTestAsn1Value(t, []byte{3, 3, 2, 2, 255, 1, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0})})
+
+	entries := []testSgxExtensionEntry{
+		{ID: OidPPID, Value: newTestAsn1Value(t, make([]byte, 16))},
+		{ID: OidTcb, Value: newTestAsn1Value(t, tcb)},
+		{ID: OidPceID, Value: newTestAsn1Value(t, []byte{0, 0})},
+		{ID: OidFmspc, Value: newTestAsn1Value(t, []byte{0x00, 0x90, 0x6e, 0xd5, 0x00, 0x00})},
+		{ID: OidSgxType, Value: newTestAsn1Value(t, asn1.Enumerated(sgxType))},
+	}
+	if configuration != nil {
+		entries = append(entries,
+			testSgxExtensionEntry{ID: OidPlatformInstanceID, Value: newTestAsn1Value(t, []byte{0xab, 0xcd, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0})},
+			testSgxExtensionEntry{ID: OidConfiguration, Value: newTestAsn1Value(t, []testSgxExtensionEntry{
+				{ID: OidDynamicPlatform, Value: newTestAsn1Value(t, configuration.DynamicPlatform)},
+				{ID: OidCachedKeys, Value: newTestAsn1Value(t, configuration.CachedKeys)},
+				{ID: OidSmtEnabled, Value: newTestAsn1Value(t, configuration.SmtEnabled)},
+			})})
+	}
+	return entries
+}
+
+func newTestSgxExtension(t testing.TB, entries []testSgxExtensionEntry) pkix.Extension {
+	t.Helper()
+	value, err := asn1.Marshal(entries)
+	require.NoError(t, err)
+	return pkix.Extension{Id: OidSgxExtensions, Value: value}
+}
+
+func TestParseSgxExtensions(t *testing.T) {
+	withoutEntry := func(entries []testSgxExtensionEntry, id asn1.ObjectIdentifier) []testSgxExtensionEntry {
+		var filtered []testSgxExtensionEntry
+		for _, entry := range entries {
+			if !entry.ID.Equal(id) {
+				filtered = append(filtered, entry)
+			}
+		}
+		return filtered
+	}
+	platformEntries := newTestSgxExtensionEntries(t, SgxTypeScalable, &Configuration{CachedKeys: true, SmtEnabled: true})
+	invalidFmspcEntries := append(withoutEntry(platformEntries, OidFmspc),
+		testSgxExtensionEntry{ID: OidFmspc, Value: newTestAsn1Value(t, []byte{0x00, 0x90})})
+
+	cases := []struct {
+		msg                      string
+		extensions               []pkix.Extension
+		wantedSgxType            SgxType
+		wantedConfiguration      *Configuration
+		wantedPlatformID         string
+		wantedDirectlyRegistered bool
+		expectError              bool
+	}{
+		{
+			msg:                      "platform CA certificate with cached keys is directly registered",
+			extensions:               []pkix.Extension{newTestSgxExtension(t, platformEntries)},
+			wantedSgxType:            SgxTypeScalable,
+			wantedConfiguration:      &Configuration{CachedKeys: true, SmtEnabled: true},
+			wantedPlatformID:         "abcd0000000000000000000000000000",
+			wantedDirectlyRegistered: true,
+		},
+		{
+			msg: "platform CA certificate without cached keys is not directly registered",
+			extensions: []pkix.Extension{newTestSgxExtension(t,
+				newTestSgxExtensionEntries(t, SgxTypeScalableWithIntegrity, &Configuration{DynamicPlatform: true}))},
+			wantedSgxType:       SgxTypeScalableWithIntegrity,
+			wantedConfiguration: &Configuration{DynamicPlatform: true},
+			wantedPlatformID:    "abcd0000000000000000000000000000",
+		},
+		{
+			msg:                      "processor CA certificate has no configuration",
+			extensions:               []pkix.Extension{newTestSgxExtension(t, newTestSgxExtensionEntries(t, SgxTypeStandard, nil))},
+			wantedSgxType:            SgxTypeStandard,
+			wantedDirectlyRegistered: true,
+		},
+		{
+			msg:         "certificate without SGX extension",
+			expectError: true,
+		},
+		{
+			msg:         "missing FMSPC",
+			extensions:  []pkix.Extension{newTestSgxExtension(t, withoutEntry(platformEntries, OidFmspc))},
+			expectError: true,
+		},
+		{
+			msg:         "truncated FMSPC",
+			extensions:  []pkix.Extension{newTestSgxExtension(t, invalidFmspcEntries)},
+			expectError: true,
+		},
+		{
+			msg:         "malformed SGX extension",
+			extensions:  []pkix.Extension{{Id: OidSgxExtensions, Value: []byte{0x30, 0x03, 0x06}}},
+			expectError: true,
+		},
+	}
+
+	for _, c := range cases {
+		chain := newTestChain(t, c.extensions...)
+		extensions, err := ParseSgxExtensions(chain.leaf.Certificate)
+		if c.expectError {
+			assert.Error(t, err, c.msg)
+			continue
+		}
+		if !assert.NoError(t, err, c.msg) {
+			continue
+		}
+		assert.Equal(t, "00000000000000000000000000000000", extensions.PPID, c.msg)
+		assert.Equal(t, "0000", extensions.PceID, c.msg)
+		assert.Equal(t, "00906ed50000", extensions.Fmspc, c.msg)
+		assert.Equal(t, Tcb{SgxTcbComponents: [16]uint8{3, 3, 2, 2, 255, 1}, PceSvn: 13}, extensions.Tcb, c.msg)
+		assert.Equal(t, [16]byte{3, 3, 2, 2, 255, 1}, extensions.CpuSvn, c.msg)
+		assert.Equal(t, c.wantedSgxType, extensions.SgxType, c.msg)
+		assert.Equal(t, c.wantedConfiguration, extensions.Configuration, c.msg)
+		assert.Equal(t, c.wantedPlatformID, extensions.PlatformInstanceID, c.msg)
+		assert.Equal(t, c.wantedDirectlyRegistered, extensions.IsDirectlyRegistered(), c.msg)
+	}
+}
+
+func TestDecodeSgxExtensions(t *testing.T) {
+	extension := newTestSgxExtension(t, newTestSgxExtensionEntries(t, SgxTypeScalable, &Configuration{CachedKeys: true}))
+	chain := newTestChain(t, extension)
+	sameChainLeaf := testcerts.New(t, "Intel SGX PCK Certificate", &chain.intermediate, false, time.Now().Add(time.Hour), extension)
+	otherPlatformLeaf := testcerts.New(t, "Intel SGX PCK Certificate", &chain.intermediate, false, time.Now().Add(time.Hour),
+		newTestSgxExtension(t, newTestSgxExtensionEntries(t, SgxTypeStandard, nil)))
+
+	cases := []struct {
+		msg         string
+		leaves      []*x509.Certificate
+		expectError bool
+	}{
+		{
+			msg:    "certificates of the same platform",
+			leaves: []*x509.Certificate{chain.leaf.Certificate, nil, sameChainLeaf.Certificate},
+		},
+		{
+			msg:         "certificates of different platforms",
+			leaves:      []*x509.Certificate{chain.leaf.Certificate, otherPlatformLeaf.Certificate},
+			expectError: true,
+		},
+		{
+			msg:         "no available certificate",
+			leaves:      []*x509.Certificate{nil},
+			expectError: true,
+		},
+	}
+
+	for _, c := range cases {
+		pckCertificates := &PckCertificates{}
+		for _, leaf := range c.leaves {
+			pckCertificates.Certificates = append(pckCertificates.Certificates, PckCertificate{Certificate: leaf})
+		}
+		extensions, err := pckCertificates.DecodeSgxExtensions()
+		if c.expectError {
+			assert.Error(t, err, c.msg)
+			assert.Nil(t, pckCertificates.Extensions, c.msg)
+			continue
+		}
+		assert.NoError(t, err, c.msg)
+		assert.Equal(t, extensions, pckCertificates.Extensions, c.msg)
+		assert.Equal(t, SgxTypeScalable, extensions.SgxType, c.msg)
+	}
+}
+
+// newTestCrl returns the DER-encoded CRL of the issuer revoking the given certificates
+func newTestCrl(t testing.TB, issuer testcerts.Certificate, revoked ...*x509.Certificate) []byte {
+	t.Helper()
+	template := &x509.RevocationList{
+		Number:     big.NewInt(1),
+		ThisUpdate: time.Now().Add(-time.Hour),
+		NextUpdate: time.Now().Add(24 * time.Hour),
+	}
+	for _, certificate := range revoked {
+		template.RevokedCertificateEntries = append(template.RevokedCertificateEntries,
+			x509.RevocationListEntry{SerialNumber: certificate.SerialNumber, RevocationTime: time.Now()})
+	}
+	der, err := x509.CreateRevocationList(rand.Reader, template, issuer.Certificate, issuer.Key)
+	require.NoError(t, err)
+	return der
+}
+
+func TestParseCrl(t *testing.T) {
+	chain := newTestChain(t)
+	der := newTestCrl(t, chain.intermediate, chain.leaf.Certificate)
+
+	cases := []struct {
+		msg         string
+		body        []byte
+		expectError bool
+	}{
+		{
+			msg:  "PEM-encoded CRL is decoded",
+			body: pem.EncodeToMemory(&pem.Block{Type: "X509 CRL", Bytes: der}),
+		},
+		{
+			msg:  "DER-encoded CRL is decoded",
+			body: der,
+		},
+		{
+			msg:  "hex-encoded CRL is decoded",
+			body: []byte(hex.EncodeToString(der) + "\n"),
+		},
+		{
+			msg:         "malformed CRL is rejected",
+			body:        []byte("not a CRL"),
+			expectError: true,
+		},
+	}
+
+	for _, c := range cases {
+		header := http.Header{}
+		header.Set(PckCrlIssuerChainHeader, chain.issuerChainHeader())
+		pckCrl, err := ParseCrl(CaTypePlatform, c.body, header)
+		if c.expectError {
+			assert.Error(t, err, c.msg)
+			continue
+		}
+		require.NoError(t, err, c.msg)
+		assert.Equal(t, CaTypePlatform, pckCrl.CaType, c.msg)
+		assert.Len(t, pckCrl.IssuerChain, 2, c.msg)
+		assert.Len(t, pckCrl.RevocationList.RevokedCertificateEntries, 1, c.msg)
+	}
+}
+
+func TestVerifyCrl(t *testing.T) {
+	chain := newTestChain(t)
+	otherChain := newTestChain(t)
+
+	cases := []struct {
+		msg         string
+		rootCA      *x509.Certificate
+		crlIssuer   testcerts.Certificate
+		issuerChain []*x509.Certificate
+		expectError bool
+	}{
+		{
+			msg:         "CRL signed by a PCK CA chaining up to the root CA is valid",
+			rootCA:      chain.root.Certificate,
+			crlIssuer:   chain.intermediate,
+			issuerChain: []*x509.Certificate{chain.intermediate.Certificate, chain.root.Certificate},
+		},
+		{
+			msg:         "CRL of another root CA is invalid",
+			rootCA:      otherChain.root.Certificate,
+			crlIssuer:   chain.intermediate,
+			issuerChain: []*x509.Certificate{chain.intermediate.Certificate, chain.root.Certificate},
+			expectError: true,
+		},
+		{
+			msg:         "CRL not signed by the issuer chain is invalid",
+			rootCA:      chain.root.Certificate,
+			crlIssuer:   otherChain.intermediate,
+			issuerChain: []*x509.Certificate{chain.intermediate.Certificate, chain.root.Certificate},
+			expectError: true,
+		},
+		{
+			msg:         "CRL without issuer chain is invalid",
+			rootCA:      chain.root.Certificate,
+			crlIssuer:   chain.intermediate,
+			expectError: true,
+		},
+	}
+
+	for _, c := range cases {
+		revocationList, err := x509.ParseRevocationList(newTestCrl(t, c.crlIssuer))
+		require.NoError(t, err, c.msg)
+		err = NewVerifier(c.rootCA).VerifyCrl(&PckCrl{RevocationList: revocationList, IssuerChain: c.issuerChain})
+		if c.expectError {
+			assert.ErrorIs(t, err, ErrChainInvalid, c.msg)
+		} else {
+			assert.NoError(t, err, c.msg)
+		}
+	}
+}
+
+func TestRevokedTcbms(t *testing.T) {
+	chain := newTestChain(t)
+	processorCA := testcerts.New(t, "Intel SGX PCK Processor CA", &chain.root, true, time.Now().Add(24*time.Hour))
+	otherLeaf := testcerts.New(t, "Intel SGX PCK Certificate", &chain.intermediate, false, time.Now().Add(24*time.Hour))
+	pckCertificates := &PckCertificates{Certificates: []PckCertificate{
+		{Tcbm: "leaf", Certificate: chain.leaf.Certificate},
+		{Tcbm: "other-leaf", Certificate: otherLeaf.Certificate},
+		{Tcbm: "not-available"},
+	}}
+
+	cases := []struct {
+		msg                string
+		crl                []byte
+		wantedRevokedTcbms []string
+	}{
+		{
+			msg:                "revoked PCK certificate is reported",
+			crl:                newTestCrl(t, chain.intermediate, chain.leaf.Certificate),
+			wantedRevokedTcbms: []string{"leaf"},
+		},
+		{
+			msg: "empty CRL revokes nothing",
+			crl: newTestCrl(t, chain.intermediate),
+		},
+		{
+			msg: "CRL of another PCK CA revokes nothing",
+			crl: newTestCrl(t, processorCA, chain.leaf.Certificate),
+		},
+	}
+
+	for _, c := range cases {
+		revocationList, err := x509.ParseRevocationList(c.crl)
+		require.NoError(t, err, c.msg)
+		pckCrl := &PckCrl{RevocationList: revocationList}
+		assert.Equal(t, c.wantedRevokedTcbms, pckCrl.RevokedTcbms(pckCertificates), c.msg)
+	}
+}
+
+func TestCrlOutdated(t *testing.T) {
+	now := time.Now()
+	cases := []struct {
+		msg            string
+		nextUpdate     time.Time
+		wantedOutdated bool
+	}{
+		{msg: "CRL before its nextUpdate is current", nextUpdate: now.Add(time.Hour)},
+		{msg: "CRL past its nextUpdate is outdated", nextUpdate: now.Add(-time.Hour), wantedOutdated: true},
+		{msg: "CRL without nextUpdate is current"},
+	}
+
+	for _, c := range cases {
+		pckCrl := &PckCrl{RevocationList: &x509.RevocationList{NextUpdate: c.nextUpdate}}
+		assert.Equal(t, c.wantedOutdated, pckCrl.Outdated(now), c.msg)
+	}
+}
+
+func TestCrlCaType(t *testing.T) {
+	chain := newTestChain(t)
+	processorCA := testcerts.New(t, "Intel SGX PCK Processor CA", &chain.root, true, time.Now().Add(24*time.Hour))
+
+	cases := []struct {
+		msg          string
+		caType       string
+		issuerChain  []*x509.Certificate
+		wantedCaType string
+		expectError  bool
+	}{
+		{
+			msg:          "CA type header is used",
+			caType:       "PROCESSOR",
+			issuerChain:  []*x509.Certificate{chain.intermediate.Certificate},
+			wantedCaType: CaTypeProcessor,
+		},
+		{
+			msg:          "platform CA type is deduced from the issuer chain",
+			issuerChain:  []*x509.Certificate{chain.intermediate.Certificate, chain.root.Certificate},
+			wantedCaType: CaTypePlatform,
+		},
+		{
+			msg:          "processor CA type is deduced from the issuer chain",
+			issuerChain:  []*x509.Certificate{processorCA.Certificate, chain.root.Certificate},
+			wantedCaType: CaTypeProcessor,
+		},
+		{
+			msg:         "unknown CA type is rejected",
+			issuerChain: []*x509.Certificate{chain.root.Certificate},
+			expectError: true,
+		},
+	}
+
+	for _, c := range cases {
+		caType, err := (&PckCertificates{CaType: c.caType, IssuerChain: c.issuerChain}).CrlCaType()
+		if c.expectError {
+			assert.Error(t, err, c.msg)
+		} else {
+			assert.NoError(t, err, c.msg)
+			assert.Equal(t, c.wantedCaType, caType, c.msg)
+		}
+	}
+}
@@ -0,0 +1,130 @@
+package pckcerts
+
+import (
+	"crypto/sha256"
+	"crypto/x509"
+	_ "embed"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+)
+
+// intelSgxRootCaPem is the Intel SGX Root CA certificate, as published by Intel at
+// https://certificates.trustedservices.intel.com/Intel_SGX_Provisioning_Certification_RootCA.pem
+//
+//go:embed intel_sgx_root_ca.pem
+var intelSgxRootCaPem string
+
+// ErrChainInvalid is returned when a PCK certificate cannot be verified up to the trusted root CA
+var ErrChainInvalid = errors.New("PCK certificate chain is invalid")
+
+// IntelSgxRootCA returns the embedded Intel SGX Root CA certificate
+func IntelSgxRootCA() *x509.Certificate {
+	certificates, err := parsePemCertificates(intelSgxRootCaPem)
+	if err != nil {
+		panic(fmt.Sprintf("invalid embedded Intel SGX Root CA: %v", err))
+	}
+	return certificates[0]
+}
+
+// LoadRootCA loads a PEM-encoded root CA certificate, used instead of the embedded Intel SGX Root CA
+// (e.g. when a PCCS re-signs the certificates or for a local stand-in)
+func LoadRootCA(path string) (*x509.Certificate, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read root CA: %w", err)
+	}
+	certificates, err := parsePemCertificates(string(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode root CA: %w", err)
+	}
+	if len(certificates) != 1 {
+		return nil, fmt.Errorf("expected one root CA certificate, got %d", len(certificates))
+	}
+	if !certificates[0].IsCA {
+		return nil, fmt.Errorf("root CA certificate %q is not a CA", certificates[0].Subject.CommonName)
+	}
+	return certificates[0], nil
+}
+
+// Fingerprint returns the hex-encoded SHA-256 fingerprint of a certificate
+func Fingerprint(certificate *x509.Certificate) string {
+	sum := sha256.Sum256(certificate.Raw)
+	return hex.EncodeToString(sum[:])
+}
+
+// Verifier verifies PCK certificates up to a trusted root CA
+type Verifier struct {
+	rootCA *x509.Certificate
+}
+
+// NewVerifier creates a Verifier trusting the given root CA, or the Intel SGX Root CA when rootCA is nil
+func NewVerifier(rootCA *x509.Certificate) *Verifier {
+	if rootCA == nil {
+		rootCA = IntelSgxRootCA()
+	}
+	return &Verifier{
+		rootCA: rootCA,
+	}
+}
+
+// RootCA returns the trusted root CA certificate
+func (v *Verifier) RootCA() *x509.Certificate {
+	return v.rootCA
+}
+
+func (v *Verifier) verifyOptions(issuerChain []*x509.Certificate) x509.VerifyOptions {
+	roots := x509.NewCertPool()
+	roots.AddCert(v.rootCA)
+	intermediates := x509.NewCertPool()
+	for _, certificate := range issuerChain {
+		intermediates.AddCert(certificate)
+	}
+	return x509.VerifyOptions{
+		Roots:         roots,
+		Intermediates: intermediates,
+		CurrentTime:   time.Now(),
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+	}
+}
+
+// VerifyIssuerChain checks that the first certificate of an issuer chain (e.g. the TCB Signing certificate)
+// chains up to the trusted root CA through the other ones, and returns it.
+// The returned error wraps ErrChainInvalid when the verification fails.
+func (v *Verifier) VerifyIssuerChain(issuerChain []*x509.Certificate) (*x509.Certificate, error) {
+	if len(issuerChain) == 0 {
+		return nil, fmt.Errorf("%w: empty issuer chain", ErrChainInvalid)
+	}
+	if _, err := issuerChain[0].Verify(v.verifyOptions(issuerChain[1:])); err != nil {
+		return nil, fmt.Errorf("%w: %s: %w", ErrChainInvalid, issuerChain[0].Subject.CommonName, err)
+	}
+	return issuerChain[0], nil
+}
+
+// Verify checks that every available PCK certificate chains up to the trusted root CA through the
+// certificates of the issuer chain, and that none of them is expired or not yet valid.
+// The returned error wraps ErrChainInvalid when the verification fails.
+func (v *Verifier) Verify(pckCertificates *PckCertificates) error {
+	if len(pckCertificates.IssuerChain) == 0 {
+		return fmt.Errorf("%w: missing %s header", ErrChainInvalid, PckCertificateIssuerChainHeader)
+	}
+
+	options := v.verifyOptions(pckCertificates.IssuerChain)
+
+	verified := 0
+	for _, pckCertificate := range pckCertificates.Certificates {
+		if pckCertificate.Certificate == nil {
+			continue
+		}
+		if _, err := pckCertificate.Certificate.Verify(options); err != nil {
+			return fmt.Errorf("%w: PCK certificate for tcbm %s: %w", ErrChainInvalid, pckCertificate.Tcbm, err)
+		}
+		verified++
+	}
+	if verified == 0 {
+		return fmt.Errorf("%w: no PCK certificate available", ErrChainInvalid)
+	}
+	return nil
+}
@@ -0,0 +1,89 @@
+package rebootsentinel
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/opensovereigncloud/cc-intel-platform-registration/pkg/metrics"
+)
+
+// Action is the change of the sentinel file made by an update
+type Action string
+
+const (
+	// ActionNone leaves the sentinel file as it is
+	ActionNone Action = ""
+	// ActionWritten creates the sentinel file
+	ActionWritten Action = "written"
+	// ActionRemoved removes the sentinel file
+	ActionRemoved Action = "removed"
+)
+
+// Sentinel signals a pending reboot through a file, following the /var/run/reboot-required convention
+// watched by kured. Only a sentinel file holding its own reason is removed, so the reboot requested by
+// another tool (e.g. a package upgrade) is never canceled.
+type Sentinel struct {
+	path   string
+	reason string
+}
+
+// NewSentinel creates a sentinel writing the given reason in the file at path
+func NewSentinel(path, reason string) *Sentinel {
+	return &Sentinel{path: path, reason: reason}
+}
+
+// Path returns the path of the sentinel file
+func (s *Sentinel) Path() string {
+	return s.path
+}
+
+// Plan returns the change an update with the given status would make: the file is written when the platform
+// waits for a reboot (05, 06 or 07) and removed once the platform is registered (09). The file is left as it
+// is for the other statuses, e.g. a failure to reach Intel after the reboot.
+func (s *Sentinel) Plan(status metrics.StatusCode) (Action, error) {
+	content, err := os.ReadFile(s.path)
+	exists := err == nil
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return ActionNone, fmt.Errorf("failed to read the reboot sentinel %s: %w", s.path, err)
+	}
+
+	switch {
+	case status.RebootNeeded() && !exists:
+		return ActionWritten, nil
+	case status == metrics.PlatformDirectlyRegistered && exists && s.owns(content):
+		return ActionRemoved, nil
+	default:
+		return ActionNone, nil
+	}
+}
+
+// Update writes or removes the sentinel file according to the status of a registration check, see Plan
+func (s *Sentinel) Update(status metrics.StatusCode) (Action, error) {
+	action, err := s.Plan(status)
+	if err != nil {
+		return ActionNone, err
+	}
+	switch action {
+	case ActionWritten:
+		if err := os.MkdirAll(filepath.Dir(s.path), 0o755); err != nil {
+			return ActionNone, fmt.Errorf("failed to create the directory of the reboot sentinel: %w", err)
+		}
+		if err := os.WriteFile(s.path, []byte(s.reason+"\n"), 0o644); err != nil {
+			return ActionNone, fmt.Errorf("failed to write the reboot sentinel: %w", err)
+		}
+	case ActionRemoved:
+		if err := os.Remove(s.path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return ActionNone, fmt.Errorf("failed to remove the reboot sentinel: %w", err)
+		}
+	}
+	return action, nil
+}
+
+// owns reports whether the content of the sentinel file was written by this sentinel
+func (s *Sentinel) owns(content []byte) bool {
+	return strings.TrimSpace(string(content)) == strings.TrimSpace(s.reason)
+}
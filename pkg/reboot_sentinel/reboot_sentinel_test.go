@@ -0,0 +1,133 @@
+package rebootsentinel
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/opensovereigncloud/cc-intel-platform-registration/pkg/metrics"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testReason = "SGX platform registration completed"
+
+func TestSentinelUpdate(t *testing.T) {
+	cases := []struct {
+		msg string
+		// existingContent is the content of the sentinel file before the update; no file when nil
+		existingContent []byte
+		status          metrics.StatusCode
+		wantedAction    Action
+		// wantedContent is the content of the sentinel file after the update; no file when nil
+		wantedContent []byte
+	}{
+		{
+			msg:           "platform reboot needed writes the sentinel",
+			status:        metrics.PlatformRebootNeeded,
+			wantedAction:  ActionWritten,
+			wantedContent: []byte(testReason + "\n"),
+		},
+		{
+			msg:           "add package reboot needed writes the sentinel",
+			status:        metrics.AddPackageRebootNeeded,
+			wantedAction:  ActionWritten,
+			wantedContent: []byte(testReason + "\n"),
+		},
+		{
+			msg:           "TCB recovery reboot needed writes the sentinel",
+			status:        metrics.TcbRecoveryRegistered,
+			wantedAction:  ActionWritten,
+			wantedContent: []byte(testReason + "\n"),
+		},
+		{
+			msg:             "sentinel already written",
+			existingContent: []byte(testReason + "\n"),
+			status:          metrics.PlatformRebootNeeded,
+			wantedAction:    ActionNone,
+			wantedContent:   []byte(testReason + "\n"),
+		},
+		{
+			msg:             "reboot already required by another tool",
+			existingContent: []byte("*** System restart required ***\n"),
+			status:          metrics.PlatformRebootNeeded,
+			wantedAction:    ActionNone,
+			wantedContent:   []byte("*** System restart required ***\n"),
+		},
+		{
+			msg:             "registered after the reboot removes the sentinel",
+			existingContent: []byte(testReason + "\n"),
+			status:          metrics.PlatformDirectlyRegistered,
+			wantedAction:    ActionRemoved,
+		},
+		{
+			msg:             "registered keeps the reboot required by another tool",
+			existingContent: []byte("*** System restart required ***\n"),
+			status:          metrics.PlatformDirectlyRegistered,
+			wantedAction:    ActionNone,
+			wantedContent:   []byte("*** System restart required ***\n"),
+		},
+		{
+			msg:          "registered without sentinel",
+			status:       metrics.PlatformDirectlyRegistered,
+			wantedAction: ActionNone,
+		},
+		{
+			msg:             "failure keeps the sentinel",
+			existingContent: []byte(testReason + "\n"),
+			status:          metrics.IntelConnectFailed,
+			wantedAction:    ActionNone,
+			wantedContent:   []byte(testReason + "\n"),
+		},
+		{
+			msg:          "failure does not write the sentinel",
+			status:       metrics.UnknownError,
+			wantedAction: ActionNone,
+		},
+	}
+
+	for _, c := range cases {
+		path := filepath.Join(t.TempDir(), "run", "reboot-required")
+		if c.existingContent != nil {
+			require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755), c.msg)
+			require.NoError(t, os.WriteFile(path, c.existingContent, 0o644), c.msg)
+		}
+		sentinel := NewSentinel(path, testReason)
+
+		plannedAction, err := sentinel.Plan(c.status)
+		assert.NoError(t, err, c.msg)
+		assert.Equal(t, c.wantedAction, plannedAction, c.msg)
+
+		action, err := sentinel.Update(c.status)
+		assert.NoError(t, err, c.msg)
+		assert.Equal(t, c.wantedAction, action, c.msg)
+
+		content, err := os.ReadFile(path)
+		if c.wantedContent == nil {
+			assert.ErrorIs(t, err, os.ErrNotExist, c.msg)
+		} else if assert.NoError(t, err, c.msg) {
+			assert.Equal(t, c.wantedContent, content, c.msg)
+		}
+	}
+}
+
+func TestSentinelPlanDoesNotWrite(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "reboot-required")
+	sentinel := NewSentinel(path, testReason)
+
+	action, err := sentinel.Plan(metrics.PlatformRebootNeeded)
+	require.NoError(t, err)
+	assert.Equal(t, ActionWritten, action)
+	_, err = os.Stat(path)
+	assert.ErrorIs(t, err, os.ErrNotExist)
+}
+
+func TestSentinelUpdateFails(t *testing.T) {
+	// the sentinel path is a directory, so it can neither be read nor written
+	path := t.TempDir()
+	sentinel := NewSentinel(path, testReason)
+
+	action, err := sentinel.Update(metrics.PlatformRebootNeeded)
+	assert.Error(t, err)
+	assert.Equal(t, ActionNone, action)
+}
@@ -0,0 +1,53 @@
+package registration
+
+import (
+	"context"
+
+	mpmanagement "github.com/opensovereigncloud/cc-intel-platform-registration/internal/pkg/mp_management"
+	sgxplatforminfo "github.com/opensovereigncloud/cc-intel-platform-registration/internal/pkg/sgx_platform_info"
+	intelservices "github.com/opensovereigncloud/cc-intel-platform-registration/pkg/intel_services"
+	"github.com/opensovereigncloud/cc-intel-platform-registration/pkg/metrics"
+	pckcerts "github.com/opensovereigncloud/cc-intel-platform-registration/pkg/pck_certs"
+	tcbinfo "github.com/opensovereigncloud/cc-intel-platform-registration/pkg/tcb_info"
+)
+
+// UefiAccessor reads and writes the SGX registration UEFI variables, as implemented by mpmanagement.MPManagement
+type UefiAccessor interface {
+	IsMachineRegistered() (bool, error)
+	GetPendingRequestType() (mpmanagement.RequestType, error)
+	GetPlatformManifest() (mpmanagement.PlatformManifest, error)
+	GetAddPackageRequest() (mpmanagement.AddPackageRequest, error)
+	SetServerResponse(response []byte) error
+	CompleteMachineRegistrationStatus() error
+	Close()
+}
+
+// UefiAccessorFactory opens the UEFI accessor used by a registration check, which closes it once done
+type UefiAccessorFactory func() UefiAccessor
+
+// PceInfoProvider retrieves the platform information (encrypted PPID, PCE-ID) from the SGX PCE
+type PceInfoProvider interface {
+	GetSgxPcePlatformInfo() (*sgxplatforminfo.SgxPcePlatformInfo, error)
+}
+
+// IntelClient sends the registration requests to the Intel RS and retrieves the platform collateral from the PCS,
+// as implemented by intelservices.IntelService
+type IntelClient interface {
+	RegisterPlatform(ctx context.Context, platformManifest mpmanagement.PlatformManifest) (metrics.StatusCodeMetric, *intelservices.IntelResponse, error)
+	RegisterAddPackage(ctx context.Context, addPackageRequest mpmanagement.AddPackageRequest) (metrics.StatusCodeMetric, []byte, *intelservices.IntelResponse, error)
+	RetrievePCK(ctx context.Context, platformInfo *sgxplatforminfo.SgxPcePlatformInfo) (metrics.StatusCodeMetric, *pckcerts.PckCertificates, *intelservices.IntelResponse, error)
+	RetrievePckCrl(ctx context.Context, caType string) (*pckcerts.PckCrl, *intelservices.IntelResponse, error)
+	RetrieveTcbInfo(ctx context.Context, fmspc string) (*tcbinfo.TcbInfo, *intelservices.IntelResponse, error)
+}
+
+// newMPManagementUefiAccessor opens the UEFI variables through the Intel MP management library
+func newMPManagementUefiAccessor() UefiAccessor {
+	return mpmanagement.NewMPManagement()
+}
+
+// sgxPceInfoProvider retrieves the platform information through the Intel SGX PCE library
+type sgxPceInfoProvider struct{}
+
+func (sgxPceInfoProvider) GetSgxPcePlatformInfo() (*sgxplatforminfo.SgxPcePlatformInfo, error) {
+	return sgxplatforminfo.GetSgxPcePlatformInfo()
+}
@@ -0,0 +1,809 @@
+package registration
+
+import (
+	"context"
+	"crypto/x509"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	mpmanagement "github.com/opensovereigncloud/cc-intel-platform-registration/internal/pkg/mp_management"
+	sgxplatforminfo "github.com/opensovereigncloud/cc-intel-platform-registration/internal/pkg/sgx_platform_info"
+	intelservices "github.com/opensovereigncloud/cc-intel-platform-registration/pkg/intel_services"
+	"github.com/opensovereigncloud/cc-intel-platform-registration/pkg/metrics"
+	pckcerts "github.com/opensovereigncloud/cc-intel-platform-registration/pkg/pck_certs"
+	rebootsentinel "github.com/opensovereigncloud/cc-intel-platform-registration/pkg/reboot_sentinel"
+	statestore "github.com/opensovereigncloud/cc-intel-platform-registration/pkg/state_store"
+	tcbinfo "github.com/opensovereigncloud/cc-intel-platform-registration/pkg/tcb_info"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+var errTest = fmt.Errorf("test error")
+
+// errNoPendingData is returned like the MP management library when the pending requests of a registered
+// platform are read
+var errNoPendingData = fmt.Errorf("no pending data")
+
+// fakeUefi is an in-memory UefiAccessor recording the writes of the checker
+type fakeUefi struct {
+	registered           bool
+	registeredErr        error
+	requestType          mpmanagement.RequestType
+	requestTypeErr       error
+	manifest             mpmanagement.PlatformManifest
+	manifestErr          error
+	addPackageRequestErr error
+	serverResponseErr    error
+	completeErr          error
+
+	serverResponse []byte
+	completed      bool
+	closed         bool
+}
+
+func (u *fakeUefi) IsMachineRegistered() (bool, error) {
+	return u.registered, u.registeredErr
+}
+
+func (u *fakeUefi) GetPendingRequestType() (mpmanagement.RequestType, error) {
+	return u.requestType, u.requestTypeErr
+}
+
+func (u *fakeUefi) GetPlatformManifest() (mpmanagement.PlatformManifest, error) {
+	if u.registered {
+		return nil, errNoPendingData
+	}
+	if u.manifest == nil {
+		return mpmanagement.PlatformManifest{0x01}, u.manifestErr
+	}
+	return u.manifest, u.manifestErr
+}
+
+func (u *fakeUefi) GetAddPackageRequest() (mpmanagement.AddPackageRequest, error) {
+	if u.registered {
+		return nil, errNoPendingData
+	}
+	return mpmanagement.AddPackageRequest{0x02}, u.addPackageRequestErr
+}
+
+func (u *fakeUefi) SetServerResponse(response []byte) error {
+	if u.serverResponseErr != nil {
+		return u.serverResponseErr
+	}
+	u.serverResponse = response
+	return nil
+}
+
+func (u *fakeUefi) CompleteMachineRegistrationStatus() error {
+	if u.completeErr != nil {
+		return u.completeErr
+	}
+	u.completed = true
+	return nil
+}
+
+func (u *fakeUefi) Close() {
+	u.closed = true
+}
+
+// newTestRegisteredUefi returns the UEFI variables of a registered platform, rebooted since its registration
+func newTestRegisteredUefi() *fakeUefi {
+	return &fakeUefi{registered: true, requestType: mpmanagement.RequestTypeNone}
+}
+
+func newTestRegisteredUefiFactory() UefiAccessorFactory {
+	return func() UefiAccessor { return newTestRegisteredUefi() }
+}
+
+type fakePceInfoProvider struct {
+	err error
+}
+
+func (p fakePceInfoProvider) GetSgxPcePlatformInfo() (*sgxplatforminfo.SgxPcePlatformInfo, error) {
+	if p.err != nil {
+		return nil, p.err
+	}
+	return &sgxplatforminfo.SgxPcePlatformInfo{EncryptedPPID: "00"}, nil
+}
+
+// fakeIntelClient returns canned Intel responses and records the requested endpoints
+type fakeIntelClient struct {
+	registerPlatformMetric metrics.StatusCodeMetric
+	registerPlatformErr    error
+	addPackageMetric       metrics.StatusCodeMetric
+	addPackageResponse     []byte
+	addPackageErr          error
+	retrievePckMetric      metrics.StatusCodeMetric
+	pckCertificates        *pckcerts.PckCertificates
+	retrievePckErr         error
+	pckCrl                 *pckcerts.PckCrl
+	pckCrlErr              error
+	tcbInfo                *tcbinfo.TcbInfo
+	tcbInfoErr             error
+
+	calls []string
+}
+
+func (c *fakeIntelClient) response(endpoint string) *intelservices.IntelResponse {
+	c.calls = append(c.calls, endpoint)
+	return &intelservices.IntelResponse{Endpoint: endpoint, RequestID: endpoint + "-request-id"}
+}
+
+func (c *fakeIntelClient) RegisterPlatform(_ context.Context, _ mpmanagement.PlatformManifest) (metrics.StatusCodeMetric, *intelservices.IntelResponse, error) {
+	return c.registerPlatformMetric, c.response("platform_registration"), c.registerPlatformErr
+}
+
+func (c *fakeIntelClient) RegisterAddPackage(_ context.Context, _ mpmanagement.AddPackageRequest) (metrics.StatusCodeMetric, []byte, *intelservices.IntelResponse, error) {
+	return c.addPackageMetric, c.addPackageResponse, c.response("add_package"), c.addPackageErr
+}
+
+func (c *fakeIntelClient) RetrievePCK(_ context.Context, _ *sgxplatforminfo.SgxPcePlatformInfo) (metrics.StatusCodeMetric, *pckcerts.PckCertificates, *intelservices.IntelResponse, error) {
+	return c.retrievePckMetric, c.pckCertificates, c.response("pck_retrieval"), c.retrievePckErr
+}
+
+func (c *fakeIntelClient) RetrievePckCrl(_ context.Context, _ string) (*pckcerts.PckCrl, *intelservices.IntelResponse, error) {
+	return c.pckCrl, c.response("pck_crl"), c.pckCrlErr
+}
+
+func (c *fakeIntelClient) RetrieveTcbInfo(_ context.Context, _ string) (*tcbinfo.TcbInfo, *intelservices.IntelResponse, error) {
+	return c.tcbInfo, c.response("tcb_info"), c.tcbInfoErr
+}
+
+var testPckCaIssuer = []byte("Test SGX PCK Platform CA")
+
+func newTestPckCertificates(serialNumber int64) *pckcerts.PckCertificates {
+	return &pckcerts.PckCertificates{
+		CaType: pckcerts.CaTypePlatform,
+		Certificates: []pckcerts.PckCertificate{{
+			Tcb:         pckcerts.Tcb{SgxTcbComponents: [pckcerts.SgxTcbComponentsCount]uint8{3, 3}, PceSvn: 13},
+			Tcbm:        "030300000000000000000000000000000d00",
+			Certificate: &x509.Certificate{SerialNumber: big.NewInt(serialNumber), RawIssuer: testPckCaIssuer},
+		}},
+		Extensions: &pckcerts.SgxExtensions{Fmspc: "00906ed50000"},
+	}
+}
+
+func newTestPckCrl(revokedSerialNumbers ...int64) *pckcerts.PckCrl {
+	revocationList := &x509.RevocationList{RawIssuer: testPckCaIssuer, NextUpdate: time.Now().Add(24 * time.Hour)}
+	for _, serialNumber := range revokedSerialNumbers {
+		revocationList.RevokedCertificateEntries = append(revocationList.RevokedCertificateEntries,
+			x509.RevocationListEntry{SerialNumber: big.NewInt(serialNumber)})
+	}
+	return &pckcerts.PckCrl{CaType: pckcerts.CaTypePlatform, RevocationList: revocationList}
+}
+
+func newTestTcbInfo() *tcbinfo.TcbInfo {
+	return &tcbinfo.TcbInfo{TcbLevels: []tcbinfo.TcbLevel{
+		{Tcb: pckcerts.Tcb{SgxTcbComponents: [pckcerts.SgxTcbComponentsCount]uint8{4, 4}, PceSvn: 13}, TcbStatus: tcbinfo.TcbStatusUpToDate},
+		{Tcb: pckcerts.Tcb{SgxTcbComponents: [pckcerts.SgxTcbComponentsCount]uint8{3, 3}, PceSvn: 13}, TcbStatus: tcbinfo.TcbStatusOutOfDate},
+	}}
+}
+
+// newTestRegisteredIntelClient returns an Intel client answering for a directly registered platform
+func newTestRegisteredIntelClient() *fakeIntelClient {
+	return &fakeIntelClient{
+		retrievePckMetric: metrics.StatusCodeMetric{Status: metrics.PlatformDirectlyRegistered},
+		pckCertificates:   newTestPckCertificates(1),
+		pckCrl:            newTestPckCrl(),
+		tcbInfo:           newTestTcbInfo(),
+	}
+}
+
+func TestDefaultRegistrationCheckerCheck(t *testing.T) {
+	revokedIntelClient := newTestRegisteredIntelClient()
+	revokedIntelClient.pckCrl = newTestPckCrl(1)
+	crlUnavailableIntelClient := newTestRegisteredIntelClient()
+	crlUnavailableIntelClient.pckCrlErr = errTest
+	tcbInfoUnavailableIntelClient := newTestRegisteredIntelClient()
+	tcbInfoUnavailableIntelClient.tcbInfoErr = errTest
+	indirectlyRegisteredIntelClient := newTestRegisteredIntelClient()
+	indirectlyRegisteredIntelClient.retrievePckMetric = metrics.StatusCodeMetric{Status: metrics.SgxResetNeeded, HttpStatusCode: "200"}
+
+	cases := []struct {
+		msg                  string
+		uefi                 *fakeUefi
+		pceErr               error
+		intelClient          *fakeIntelClient
+		wantedStatus         metrics.StatusCode
+		expectError          bool
+		wantedCalls          []string
+		wantedCompleted      bool
+		wantedServerResponse []byte
+		wantedTcbStatus      tcbinfo.TcbStatus
+		// wantedRebootPending expects the registration completion time to be reported
+		wantedRebootPending bool
+		// registration is the registration history restored before the check
+		registration statestore.Registration
+	}{
+		{
+			msg:          "unreadable registration status reports unavailable UEFI variables",
+			uefi:         &fakeUefi{registeredErr: errTest},
+			intelClient:  &fakeIntelClient{},
+			wantedStatus: metrics.SgxUefiUnavailable,
+			expectError:  true,
+		},
+		{
+			msg:          "unreadable pending request type reports unavailable UEFI variables",
+			uefi:         &fakeUefi{requestTypeErr: errTest},
+			intelClient:  &fakeIntelClient{},
+			wantedStatus: metrics.SgxUefiUnavailable,
+			expectError:  true,
+		},
+		{
+			msg:          "unreadable platform manifest reports unavailable UEFI variables",
+			uefi:         &fakeUefi{requestType: mpmanagement.RequestTypeRegistration, manifestErr: errTest},
+			intelClient:  &fakeIntelClient{},
+			wantedStatus: metrics.SgxUefiUnavailable,
+			expectError:  true,
+		},
+		{
+			msg:                 "registered platform manifest completes the registration",
+			uefi:                &fakeUefi{requestType: mpmanagement.RequestTypeRegistration},
+			intelClient:         &fakeIntelClient{registerPlatformMetric: metrics.StatusCodeMetric{Status: metrics.PlatformRebootNeeded}},
+			wantedStatus:        metrics.PlatformRebootNeeded,
+			wantedCalls:         []string{"platform_registration"},
+			wantedCompleted:     true,
+			wantedRebootPending: true,
+		},
+		{
+			msg:          "failure to complete the registration reports a UEFI persist failure",
+			uefi:         &fakeUefi{requestType: mpmanagement.RequestTypeRegistration, completeErr: errTest},
+			intelClient:  &fakeIntelClient{registerPlatformMetric: metrics.StatusCodeMetric{Status: metrics.PlatformRebootNeeded}},
+			wantedStatus: metrics.UefiPersistFailed,
+			expectError:  true,
+			wantedCalls:  []string{"platform_registration"},
+		},
+		{
+			msg:  "invalid registration request does not complete the registration",
+			uefi: &fakeUefi{requestType: mpmanagement.RequestTypeRegistration},
+			intelClient: &fakeIntelClient{registerPlatformMetric: metrics.StatusCodeMetric{
+				Status: metrics.InvalidRegistrationRequest, HttpStatusCode: "400", IntelError: "InvalidRequestSyntax"}},
+			wantedStatus: metrics.InvalidRegistrationRequest,
+			wantedCalls:  []string{"platform_registration"},
+		},
+		{
+			msg:  "Intel RS failure does not complete the registration",
+			uefi: &fakeUefi{requestType: mpmanagement.RequestTypeRegistration},
+			intelClient: &fakeIntelClient{registerPlatformMetric: metrics.StatusCodeMetric{
+				Status: metrics.IntelRegServiceRequestFailed, HttpStatusCode: "500"}},
+			wantedStatus: metrics.IntelRegServiceRequestFailed,
+			wantedCalls:  []string{"platform_registration"},
+		},
+		{
+			msg:  "unreachable Intel RS does not complete the registration",
+			uefi: &fakeUefi{requestType: mpmanagement.RequestTypeRegistration},
+			intelClient: &fakeIntelClient{
+				registerPlatformMetric: metrics.StatusCodeMetric{Status: metrics.IntelConnectFailed}, registerPlatformErr: errTest},
+			wantedStatus: metrics.IntelConnectFailed,
+			expectError:  true,
+			wantedCalls:  []string{"platform_registration"},
+		},
+		{
+			msg:          "unreadable AddPackage request reports unavailable UEFI variables",
+			uefi:         &fakeUefi{requestType: mpmanagement.RequestTypeAddPackage, addPackageRequestErr: errTest},
+			intelClient:  &fakeIntelClient{},
+			wantedStatus: metrics.SgxUefiUnavailable,
+			expectError:  true,
+		},
+		{
+			msg:  "registered AddPackage request writes the server response and completes the registration",
+			uefi: &fakeUefi{requestType: mpmanagement.RequestTypeAddPackage},
+			intelClient: &fakeIntelClient{
+				addPackageMetric: metrics.StatusCodeMetric{Status: metrics.AddPackageRebootNeeded}, addPackageResponse: []byte{0x03}},
+			wantedStatus:         metrics.AddPackageRebootNeeded,
+			wantedCalls:          []string{"add_package"},
+			wantedCompleted:      true,
+			wantedServerResponse: []byte{0x03},
+			wantedRebootPending:  true,
+		},
+		{
+			msg:  "failure to write the server response reports a UEFI persist failure",
+			uefi: &fakeUefi{requestType: mpmanagement.RequestTypeAddPackage, serverResponseErr: errTest},
+			intelClient: &fakeIntelClient{
+				addPackageMetric: metrics.StatusCodeMetric{Status: metrics.AddPackageRebootNeeded}, addPackageResponse: []byte{0x03}},
+			wantedStatus: metrics.UefiPersistFailed,
+			expectError:  true,
+			wantedCalls:  []string{"add_package"},
+		},
+		{
+			msg:  "failure to complete the AddPackage registration reports a UEFI persist failure",
+			uefi: &fakeUefi{requestType: mpmanagement.RequestTypeAddPackage, completeErr: errTest},
+			intelClient: &fakeIntelClient{
+				addPackageMetric: metrics.StatusCodeMetric{Status: metrics.AddPackageRebootNeeded}, addPackageResponse: []byte{0x03}},
+			wantedStatus:         metrics.UefiPersistFailed,
+			expectError:          true,
+			wantedCalls:          []string{"add_package"},
+			wantedServerResponse: []byte{0x03},
+		},
+		{
+			msg:  "invalid AddPackage request writes nothing to the UEFI",
+			uefi: &fakeUefi{requestType: mpmanagement.RequestTypeAddPackage},
+			intelClient: &fakeIntelClient{addPackageMetric: metrics.StatusCodeMetric{
+				Status: metrics.InvalidAddPackageRequest, HttpStatusCode: "400"}},
+			wantedStatus: metrics.InvalidAddPackageRequest,
+			wantedCalls:  []string{"add_package"},
+		},
+		{
+			msg:          "unreadable registration request of a registered platform reports unavailable UEFI variables",
+			uefi:         &fakeUefi{registered: true, requestTypeErr: errTest},
+			intelClient:  &fakeIntelClient{},
+			wantedStatus: metrics.SgxUefiUnavailable,
+			expectError:  true,
+		},
+		{
+			msg:                 "registered platform with a pending platform manifest waits for a reboot",
+			uefi:                &fakeUefi{registered: true, requestType: mpmanagement.RequestTypeRegistration},
+			intelClient:         &fakeIntelClient{},
+			wantedStatus:        metrics.PlatformRebootNeeded,
+			wantedRebootPending: true,
+		},
+		{
+			msg:                 "registered platform with a pending AddPackage request waits for a reboot",
+			uefi:                &fakeUefi{registered: true, requestType: mpmanagement.RequestTypeAddPackage},
+			intelClient:         &fakeIntelClient{},
+			wantedStatus:        metrics.AddPackageRebootNeeded,
+			wantedRebootPending: true,
+		},
+		{
+			msg:                 "registered platform with a pending TCB recovery registration waits for a reboot",
+			uefi:                &fakeUefi{registered: true, requestType: mpmanagement.RequestTypeRegistration},
+			registration:        statestore.Registration{WasRegistered: true, TcbRecovery: true},
+			intelClient:         &fakeIntelClient{},
+			wantedStatus:        metrics.TcbRecoveryRegistered,
+			wantedRebootPending: true,
+		},
+		{
+			msg:          "unavailable PCE info needs a retry",
+			uefi:         newTestRegisteredUefi(),
+			pceErr:       errTest,
+			intelClient:  &fakeIntelClient{},
+			wantedStatus: metrics.RetryNeeded,
+			expectError:  true,
+		},
+		{
+			msg:             "directly registered platform is checked for revocation and evaluated",
+			uefi:            newTestRegisteredUefi(),
+			intelClient:     newTestRegisteredIntelClient(),
+			wantedStatus:    metrics.PlatformDirectlyRegistered,
+			wantedCalls:     []string{"pck_retrieval", "pck_crl", "tcb_info"},
+			wantedTcbStatus: tcbinfo.TcbStatusOutOfDate,
+		},
+		{
+			msg:             "indirectly registered platform needs an SGX reset",
+			uefi:            newTestRegisteredUefi(),
+			intelClient:     indirectlyRegisteredIntelClient,
+			wantedStatus:    metrics.SgxResetNeeded,
+			wantedCalls:     []string{"pck_retrieval", "pck_crl", "tcb_info"},
+			wantedTcbStatus: tcbinfo.TcbStatusOutOfDate,
+		},
+		{
+			msg:             "revoked PCK certificate reports a revoked platform",
+			uefi:            newTestRegisteredUefi(),
+			intelClient:     revokedIntelClient,
+			wantedStatus:    metrics.PlatformRevoked,
+			wantedCalls:     []string{"pck_retrieval", "pck_crl", "tcb_info"},
+			wantedTcbStatus: tcbinfo.TcbStatusOutOfDate,
+		},
+		{
+			msg:             "unavailable PCK CRL keeps the registration status",
+			uefi:            newTestRegisteredUefi(),
+			intelClient:     crlUnavailableIntelClient,
+			wantedStatus:    metrics.PlatformDirectlyRegistered,
+			wantedCalls:     []string{"pck_retrieval", "pck_crl", "tcb_info"},
+			wantedTcbStatus: tcbinfo.TcbStatusOutOfDate,
+		},
+		{
+			msg:          "unavailable TCB Info keeps the registration status",
+			uefi:         newTestRegisteredUefi(),
+			intelClient:  tcbInfoUnavailableIntelClient,
+			wantedStatus: metrics.PlatformDirectlyRegistered,
+			wantedCalls:  []string{"pck_retrieval", "pck_crl", "tcb_info"},
+		},
+		{
+			msg:  "platform unknown to the PCS needs an SGX reset",
+			uefi: newTestRegisteredUefi(),
+			intelClient: &fakeIntelClient{retrievePckMetric: metrics.StatusCodeMetric{
+				Status: metrics.SgxResetNeeded, HttpStatusCode: "404"}},
+			wantedStatus: metrics.SgxResetNeeded,
+			wantedCalls:  []string{"pck_retrieval"},
+		},
+		{
+			msg:  "invalid PCK certificate chain is reported",
+			uefi: newTestRegisteredUefi(),
+			intelClient: &fakeIntelClient{
+				retrievePckMetric: metrics.StatusCodeMetric{Status: metrics.PckCertChainInvalid, HttpStatusCode: "200"},
+				retrievePckErr:    errTest},
+			wantedStatus: metrics.PckCertChainInvalid,
+			expectError:  true,
+			wantedCalls:  []string{"pck_retrieval"},
+		},
+		{
+			msg:  "PCS failure needs a retry",
+			uefi: newTestRegisteredUefi(),
+			intelClient: &fakeIntelClient{retrievePckMetric: metrics.StatusCodeMetric{
+				Status: metrics.RetryNeeded, HttpStatusCode: "500"}},
+			wantedStatus: metrics.RetryNeeded,
+			wantedCalls:  []string{"pck_retrieval"},
+		},
+	}
+
+	for _, c := range cases {
+		checker := NewRegistrationChecker(zap.NewNop(), func() UefiAccessor { return c.uefi },
+			fakePceInfoProvider{err: c.pceErr}, c.intelClient)
+		checker.restore(c.registration)
+		checkResult, err := checker.Check(context.Background())
+
+		if c.expectError {
+			assert.Error(t, err, c.msg)
+		} else {
+			assert.NoError(t, err, c.msg)
+		}
+		assert.Equal(t, c.wantedStatus, checkResult.Status, c.msg)
+		assert.Equal(t, c.wantedCalls, c.intelClient.calls, c.msg)
+		assert.Len(t, checkResult.IntelRequestIDs(), len(c.wantedCalls), c.msg)
+		assert.Equal(t, c.wantedCompleted, c.uefi.completed, c.msg)
+		assert.Equal(t, c.wantedServerResponse, c.uefi.serverResponse, c.msg)
+		assert.True(t, c.uefi.closed, c.msg)
+		assert.Equal(t, c.wantedRebootPending, !checkResult.RegistrationCompletedAt.IsZero(), c.msg)
+		if c.wantedTcbStatus != "" && assert.NotNil(t, checkResult.TcbEvaluation, c.msg) {
+			assert.Equal(t, c.wantedTcbStatus, checkResult.TcbEvaluation.Status, c.msg)
+		} else if c.wantedTcbStatus == "" {
+			assert.Nil(t, checkResult.TcbEvaluation, c.msg)
+		}
+	}
+}
+
+func TestDefaultRegistrationCheckerCachesPckCrl(t *testing.T) {
+	intelClient := newTestRegisteredIntelClient()
+	intelClient.pckCrl = newTestPckCrl(1)
+	checker := NewRegistrationChecker(zap.NewNop(), newTestRegisteredUefiFactory(),
+		fakePceInfoProvider{}, intelClient)
+
+	checkResult, err := checker.Check(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, metrics.PlatformRevoked, checkResult.Status)
+
+	// the cached CRL is reused until it must be refreshed
+	intelClient.calls = nil
+	checkResult, err = checker.Check(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, metrics.PlatformRevoked, checkResult.Status)
+	assert.Equal(t, []string{"pck_retrieval", "tcb_info"}, intelClient.calls)
+
+	// the last retrieved CRL is used when the refresh fails
+	checker.pckCrls[pckcerts.CaTypePlatform].retrievedAt = time.Now().Add(-25 * time.Hour)
+	intelClient.pckCrl, intelClient.pckCrlErr = nil, errTest
+	intelClient.calls = nil
+	checkResult, err = checker.Check(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, metrics.PlatformRevoked, checkResult.Status)
+	assert.Equal(t, []string{"pck_retrieval", "pck_crl", "tcb_info"}, intelClient.calls)
+}
+
+func TestDefaultRegistrationCheckerWarnsOfOutdatedPckCrl(t *testing.T) {
+	cases := []struct {
+		msg          string
+		nextUpdate   time.Time
+		wantedStatus metrics.StatusCode
+		wantedWarned bool
+	}{
+		{
+			msg:          "current CRL",
+			nextUpdate:   time.Now().Add(time.Hour),
+			wantedStatus: metrics.PlatformRevoked,
+		},
+		{
+			msg:          "outdated CRL is still used for the revocation check",
+			nextUpdate:   time.Now().Add(-time.Hour),
+			wantedStatus: metrics.PlatformRevoked,
+			wantedWarned: true,
+		},
+	}
+
+	for _, c := range cases {
+		observedZapCore, observedLogs := observer.New(zap.WarnLevel)
+		intelClient := newTestRegisteredIntelClient()
+		intelClient.pckCrl = newTestPckCrl(1)
+		intelClient.pckCrl.RevocationList.NextUpdate = c.nextUpdate
+		checker := NewRegistrationChecker(zap.New(observedZapCore), newTestRegisteredUefiFactory(),
+			fakePceInfoProvider{}, intelClient)
+
+		checkResult, err := checker.Check(context.Background())
+		assert.NoError(t, err, c.msg)
+		assert.Equal(t, c.wantedStatus, checkResult.Status, c.msg)
+		warnings := observedLogs.FilterMessageSnippet("PCK CRL is outdated").Len()
+		assert.Equal(t, c.wantedWarned, warnings == 1, c.msg)
+	}
+}
+
+func TestDefaultRegistrationCheckerSkipsCollateralWhenCanceled(t *testing.T) {
+	intelClient := newTestRegisteredIntelClient()
+	checker := NewRegistrationChecker(zap.NewNop(), newTestRegisteredUefiFactory(),
+		fakePceInfoProvider{}, intelClient)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	checkResult, err := checker.Check(ctx)
+
+	assert.NoError(t, err)
+	assert.Equal(t, metrics.PlatformDirectlyRegistered, checkResult.Status)
+	assert.Equal(t, []string{"pck_retrieval"}, intelClient.calls)
+	assert.Nil(t, checkResult.PckCrl)
+	assert.Nil(t, checkResult.TcbEvaluation)
+}
+
+func TestDefaultRegistrationCheckerTracksPendingReboot(t *testing.T) {
+	uefi := &fakeUefi{requestType: mpmanagement.RequestTypeRegistration}
+	intelClient := &fakeIntelClient{registerPlatformMetric: metrics.StatusCodeMetric{Status: metrics.PlatformRebootNeeded}}
+	checker := NewRegistrationChecker(zap.NewNop(), func() UefiAccessor { return uefi }, fakePceInfoProvider{}, intelClient)
+
+	checkResult, err := checker.Check(context.Background())
+	assert.NoError(t, err)
+	registrationCompletedAt := checkResult.RegistrationCompletedAt
+	assert.False(t, registrationCompletedAt.IsZero())
+
+	// the registration is complete but the BIOS did not consume the platform manifest yet
+	uefi.registered = uefi.completed
+	checkResult, err = checker.Check(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, metrics.PlatformRebootNeeded, checkResult.Status)
+	assert.Equal(t, registrationCompletedAt, checkResult.RegistrationCompletedAt)
+	assert.Equal(t, []string{"platform_registration"}, intelClient.calls)
+
+	// the platform rebooted
+	uefi.requestType = mpmanagement.RequestTypeNone
+	intelClient.retrievePckMetric = metrics.StatusCodeMetric{Status: metrics.PlatformDirectlyRegistered}
+	checkResult, err = checker.Check(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, metrics.PlatformDirectlyRegistered, checkResult.Status)
+	assert.True(t, checkResult.RegistrationCompletedAt.IsZero())
+}
+
+func TestDefaultRegistrationCheckerRegistersTcbRecovery(t *testing.T) {
+	cases := []struct {
+		msg string
+		// recover updates the UEFI variables of the registered platform as the BIOS does after a TCB recovery or an SGX reset
+		recover func(uefi *fakeUefi)
+	}{
+		{
+			msg: "TCB recovery or SGX reset clearing the registration status",
+			recover: func(uefi *fakeUefi) {
+				uefi.registered, uefi.requestType, uefi.manifest = false, mpmanagement.RequestTypeRegistration, mpmanagement.PlatformManifest{0x02}
+			},
+		},
+	}
+
+	for _, c := range cases {
+		uefi := &fakeUefi{requestType: mpmanagement.RequestTypeRegistration}
+		intelClient := &fakeIntelClient{
+			registerPlatformMetric: metrics.StatusCodeMetric{Status: metrics.PlatformRebootNeeded},
+			retrievePckMetric:      metrics.StatusCodeMetric{Status: metrics.PlatformDirectlyRegistered},
+		}
+		checker := NewRegistrationChecker(zap.NewNop(), func() UefiAccessor { return uefi }, fakePceInfoProvider{}, intelClient)
+		check := func() metrics.StatusCode {
+			checkResult, err := checker.Check(context.Background())
+			assert.NoError(t, err, c.msg)
+			return checkResult.Status
+		}
+
+		// first registration, then reboot
+		assert.Equal(t, metrics.PlatformRebootNeeded, check(), c.msg)
+		uefi.registered = true
+		assert.Equal(t, metrics.PlatformRebootNeeded, check(), c.msg)
+		uefi.requestType = mpmanagement.RequestTypeNone
+		assert.Equal(t, metrics.PlatformDirectlyRegistered, check(), c.msg)
+
+		registrations := testutil.ToFloat64(metrics.TcbRecoveryRegistrationsMetric)
+		c.recover(uefi)
+		assert.Equal(t, metrics.TcbRecoveryRegistered, check(), c.msg)
+		assert.Equal(t, registrations+1, testutil.ToFloat64(metrics.TcbRecoveryRegistrationsMetric), c.msg)
+
+		// the new manifest is only registered once, until the reboot
+		uefi.registered = true
+		assert.Equal(t, metrics.TcbRecoveryRegistered, check(), c.msg)
+		uefi.requestType = mpmanagement.RequestTypeNone
+		assert.Equal(t, metrics.PlatformDirectlyRegistered, check(), c.msg)
+		assert.Equal(t, []string{"platform_registration", "pck_retrieval", "platform_registration", "pck_retrieval"}, intelClient.calls, c.msg)
+		assert.Equal(t, registrations+1, testutil.ToFloat64(metrics.TcbRecoveryRegistrationsMetric), c.msg)
+	}
+}
+
+func TestDefaultRegistrationCheckerRegistersManifestOfPlatformRegisteredBeforeStart(t *testing.T) {
+	// the service starts on a registered platform and never saw its manifest
+	uefi := newTestRegisteredUefi()
+	intelClient := &fakeIntelClient{
+		registerPlatformMetric: metrics.StatusCodeMetric{Status: metrics.PlatformRebootNeeded},
+		retrievePckMetric:      metrics.StatusCodeMetric{Status: metrics.PlatformDirectlyRegistered},
+	}
+	checker := NewRegistrationChecker(zap.NewNop(), func() UefiAccessor { return uefi }, fakePceInfoProvider{}, intelClient)
+
+	checkResult, err := checker.Check(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, metrics.PlatformDirectlyRegistered, checkResult.Status)
+
+	uefi.registered, uefi.requestType = false, mpmanagement.RequestTypeRegistration
+	checkResult, err = checker.Check(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, metrics.TcbRecoveryRegistered, checkResult.Status)
+}
+
+func TestRegistrationServicePersistsState(t *testing.T) {
+	stateDir := t.TempDir()
+	newService := func(uefi *fakeUefi, intelClient *fakeIntelClient) (*RegistrationService, *DefaultRegistrationChecker) {
+		checker := NewRegistrationChecker(zap.NewNop(), func() UefiAccessor { return uefi }, fakePceInfoProvider{}, intelClient)
+		registrationService := &RegistrationService{
+			checkTimeout:        time.Minute,
+			serverMetrics:       metrics.NewRegistrationServiceMetricsRegistry(zap.NewNop()),
+			registrationChecker: checker,
+			log:                 zap.NewNop(),
+			stateStore:          statestore.NewStore(stateDir, 10),
+		}
+		registrationService.restoreState(checker)
+		return registrationService, checker
+	}
+
+	// the platform is registered, then the service restarts before the reboot
+	uefi := &fakeUefi{requestType: mpmanagement.RequestTypeRegistration}
+	intelClient := &fakeIntelClient{
+		registerPlatformMetric: metrics.StatusCodeMetric{Status: metrics.PlatformRebootNeeded},
+		retrievePckMetric:      metrics.StatusCodeMetric{Status: metrics.PlatformDirectlyRegistered},
+	}
+	registrationService, _ := newService(uefi, intelClient)
+	assert.Nil(t, registrationService.LastCheckResult())
+	registrationService.CheckRegistrationStatus(context.Background())
+	registered := registrationService.LastCheckResult()
+
+	uefi.registered = true
+	registrationService, checker := newService(uefi, intelClient)
+	if assert.NotNil(t, registrationService.LastCheckResult()) {
+		assert.Equal(t, metrics.PlatformRebootNeeded, registrationService.LastCheckResult().Status)
+		assert.Equal(t, registered.ManifestFingerprint, registrationService.LastCheckResult().ManifestFingerprint)
+	}
+	assert.Equal(t, registered.Registration.ManifestFingerprint, checker.registration.ManifestFingerprint)
+	assert.True(t, checker.registration.WasRegistered)
+
+	// the registration time survives the restart
+	registrationService.CheckRegistrationStatus(context.Background())
+	assert.Equal(t, metrics.PlatformRebootNeeded, registrationService.LastCheckResult().Status)
+	assert.True(t, registered.RegistrationCompletedAt.Equal(registrationService.LastCheckResult().RegistrationCompletedAt))
+
+	uefi.requestType = mpmanagement.RequestTypeNone
+	registrationService.CheckRegistrationStatus(context.Background())
+
+	state, err := statestore.NewStore(stateDir, 10).Load()
+	require.NoError(t, err)
+	assert.Len(t, state.Checks, 3)
+	assert.Equal(t, []statestore.Transition{
+		{Time: state.Transitions[0].Time, From: metrics.Pending, To: metrics.PlatformRebootNeeded},
+		{Time: state.Transitions[1].Time, From: metrics.PlatformRebootNeeded, To: metrics.PlatformDirectlyRegistered},
+	}, state.Transitions)
+	assert.Equal(t, []string{"platform_registration-request-id"}, state.Checks[0].IntelRequestIDs)
+	assert.True(t, state.Registration.CompletedAt.IsZero())
+}
+
+func TestRegistrationServiceIgnoresInvalidState(t *testing.T) {
+	stateDir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(stateDir, statestore.StateFileName), []byte("{"), 0o600))
+	checker := NewRegistrationChecker(zap.NewNop(), newTestRegisteredUefiFactory(), fakePceInfoProvider{}, newTestRegisteredIntelClient())
+	registrationService := &RegistrationService{
+		checkTimeout:        time.Minute,
+		serverMetrics:       metrics.NewRegistrationServiceMetricsRegistry(zap.NewNop()),
+		registrationChecker: checker,
+		log:                 zap.NewNop(),
+		stateStore:          statestore.NewStore(stateDir, 10),
+	}
+
+	registrationService.restoreState(checker)
+	assert.Nil(t, registrationService.LastCheckResult())
+
+	// the invalid state is replaced at the next check
+	registrationService.CheckRegistrationStatus(context.Background())
+	state, err := statestore.NewStore(stateDir, 10).Load()
+	require.NoError(t, err)
+	if assert.NotNil(t, state.LastCheck()) {
+		assert.Equal(t, metrics.PlatformDirectlyRegistered, state.LastCheck().Status)
+	}
+}
+
+func TestRegistrationServiceSkipsInvalidCheck(t *testing.T) {
+	stateDir := t.TempDir()
+	uefi := &fakeUefi{requestType: mpmanagement.RequestTypeRegistration}
+	// the Intel response lacks the Error-Code header an invalid registration request requires
+	intelClient := &fakeIntelClient{registerPlatformMetric: metrics.StatusCodeMetric{
+		Status: metrics.InvalidRegistrationRequest, HttpStatusCode: "400"}}
+	checker := NewRegistrationChecker(zap.NewNop(), func() UefiAccessor { return uefi }, fakePceInfoProvider{}, intelClient)
+	registrationService := &RegistrationService{
+		checkTimeout:        time.Minute,
+		serverMetrics:       metrics.NewRegistrationServiceMetricsRegistry(zap.NewNop()),
+		registrationChecker: checker,
+		log:                 zap.NewNop(),
+		stateStore:          statestore.NewStore(stateDir, 10),
+	}
+	registrationService.restoreState(checker)
+
+	checkResult := registrationService.CheckRegistrationStatus(context.Background())
+	assert.Equal(t, metrics.InvalidRegistrationRequest, checkResult.Status)
+	state, err := statestore.NewStore(stateDir, 10).Load()
+	require.NoError(t, err)
+	assert.Nil(t, state.LastCheck())
+}
+
+func TestRegistrationServiceRunRestoresInvalidCheck(t *testing.T) {
+	stateDir := t.TempDir()
+	// e.g. persisted by a release recording the checks without validating them
+	require.NoError(t, statestore.NewStore(stateDir, 10).Save(&statestore.State{
+		Checks: []statestore.CheckRecord{{Time: time.Now(), Status: metrics.InvalidRegistrationRequest}},
+	}))
+	checker := NewRegistrationChecker(zap.NewNop(), newTestRegisteredUefiFactory(), fakePceInfoProvider{}, newTestRegisteredIntelClient())
+	registrationService := &RegistrationService{
+		schedulePolicy:      DefaultSchedulePolicy(),
+		checkTimeout:        time.Minute,
+		serverMetrics:       metrics.NewRegistrationServiceMetricsRegistry(zap.NewNop()),
+		registrationChecker: checker,
+		log:                 zap.NewNop(),
+		stateStore:          statestore.NewStore(stateDir, 10),
+	}
+	registrationService.restoreState(checker)
+
+	// the service starts pending and runs its first check
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	assert.NoError(t, registrationService.Run(ctx))
+	if assert.NotNil(t, registrationService.LastCheckResult()) {
+		assert.Equal(t, metrics.PlatformDirectlyRegistered, registrationService.LastCheckResult().Status)
+		assert.Equal(t, metrics.InvalidRegistrationRequest, registrationService.LastCheckResult().PreviousStatus)
+	}
+}
+
+func TestRegistrationServiceUpdatesRebootSentinel(t *testing.T) {
+	sentinelPath := filepath.Join(t.TempDir(), "reboot-required")
+	uefi := &fakeUefi{requestType: mpmanagement.RequestTypeRegistration}
+	intelClient := newTestRegisteredIntelClient()
+	intelClient.registerPlatformMetric = metrics.StatusCodeMetric{Status: metrics.PlatformRebootNeeded}
+	registrationService := &RegistrationService{
+		checkTimeout:        time.Minute,
+		serverMetrics:       metrics.NewRegistrationServiceMetricsRegistry(zap.NewNop()),
+		registrationChecker: NewRegistrationChecker(zap.NewNop(), func() UefiAccessor { return uefi }, fakePceInfoProvider{}, intelClient),
+		log:                 zap.NewNop(),
+		rebootSentinel:      rebootsentinel.NewSentinel(sentinelPath, "reboot for SGX"),
+	}
+
+	// the sentinel is written at the registration and kept until the reboot
+	registrationService.CheckRegistrationStatus(context.Background())
+	assert.FileExists(t, sentinelPath)
+	uefi.registered = true
+	registrationService.CheckRegistrationStatus(context.Background())
+	assert.FileExists(t, sentinelPath)
+
+	uefi.requestType = mpmanagement.RequestTypeNone
+	checkResult := registrationService.CheckRegistrationStatus(context.Background())
+	assert.Equal(t, metrics.PlatformDirectlyRegistered, checkResult.Status)
+	assert.NoFileExists(t, sentinelPath)
+}
+
+func TestRegistrationServiceDryRunListsRebootSentinel(t *testing.T) {
+	sentinelPath := filepath.Join(t.TempDir(), "reboot-required")
+	uefi := &fakeUefi{requestType: mpmanagement.RequestTypeRegistration}
+	checker := NewRegistrationChecker(zap.NewNop(), func() UefiAccessor { return uefi }, fakePceInfoProvider{}, newTestRegisteredIntelClient())
+	checker.enableDryRun()
+	registrationService := &RegistrationService{
+		checkTimeout:        time.Minute,
+		serverMetrics:       metrics.NewRegistrationServiceMetricsRegistry(zap.NewNop()),
+		registrationChecker: checker,
+		log:                 zap.NewNop(),
+		dryRun:              true,
+		rebootSentinel:      rebootsentinel.NewSentinel(sentinelPath, "reboot for SGX"),
+	}
+
+	checkResult := registrationService.CheckRegistrationStatus(context.Background())
+	assert.Equal(t, metrics.PlatformRebootNeeded, checkResult.Status)
+	assert.Contains(t, checkResult.DryRunActions, "write the reboot sentinel "+sentinelPath)
+	assert.NoFileExists(t, sentinelPath)
+}
@@ -0,0 +1,117 @@
+package registration
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	mpmanagement "github.com/opensovereigncloud/cc-intel-platform-registration/internal/pkg/mp_management"
+	sgxplatforminfo "github.com/opensovereigncloud/cc-intel-platform-registration/internal/pkg/sgx_platform_info"
+	intelservices "github.com/opensovereigncloud/cc-intel-platform-registration/pkg/intel_services"
+	"github.com/opensovereigncloud/cc-intel-platform-registration/pkg/metrics"
+	pckcerts "github.com/opensovereigncloud/cc-intel-platform-registration/pkg/pck_certs"
+	tcbinfo "github.com/opensovereigncloud/cc-intel-platform-registration/pkg/tcb_info"
+	"go.uber.org/zap"
+)
+
+// dryRunRecorder collects the write and network actions skipped by a dry-run check
+type dryRunRecorder struct {
+	log     *zap.Logger
+	mutex   sync.Mutex
+	actions []string
+}
+
+func (r *dryRunRecorder) record(action string) {
+	r.log.Info("dry run, action skipped", zap.String("action", action))
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.actions = append(r.actions, action)
+}
+
+// take returns the actions recorded since the last call
+func (r *dryRunRecorder) take() []string {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	actions := r.actions
+	r.actions = nil
+	return actions
+}
+
+// dryRunUefiAccessor reads the UEFI variables and records the writes instead of performing them.
+// Every method is delegated explicitly, so a write added to UefiAccessor cannot reach the UEFI unnoticed.
+type dryRunUefiAccessor struct {
+	uefi     UefiAccessor
+	recorder *dryRunRecorder
+}
+
+func (d dryRunUefiAccessor) IsMachineRegistered() (bool, error) {
+	return d.uefi.IsMachineRegistered()
+}
+
+func (d dryRunUefiAccessor) GetPendingRequestType() (mpmanagement.RequestType, error) {
+	return d.uefi.GetPendingRequestType()
+}
+
+func (d dryRunUefiAccessor) GetPlatformManifest() (mpmanagement.PlatformManifest, error) {
+	return d.uefi.GetPlatformManifest()
+}
+
+func (d dryRunUefiAccessor) GetAddPackageRequest() (mpmanagement.AddPackageRequest, error) {
+	return d.uefi.GetAddPackageRequest()
+}
+
+func (d dryRunUefiAccessor) SetServerResponse(response []byte) error {
+	d.recorder.record("write the Intel RS AddPackage response to the UEFI server response variable")
+	return nil
+}
+
+func (d dryRunUefiAccessor) CompleteMachineRegistrationStatus() error {
+	d.recorder.record("set SgxRegistrationComplete in the UEFI variables")
+	return nil
+}
+
+func (d dryRunUefiAccessor) Close() {
+	d.uefi.Close()
+}
+
+// dryRunIntelClient retrieves the platform collateral and records the registration requests instead of sending
+// them, answering them as the Intel RS would on success
+type dryRunIntelClient struct {
+	intelClient IntelClient
+	recorder    *dryRunRecorder
+}
+
+func (d dryRunIntelClient) RegisterPlatform(_ context.Context, platformManifest mpmanagement.PlatformManifest) (metrics.StatusCodeMetric, *intelservices.IntelResponse, error) {
+	d.recorder.record(fmt.Sprintf("POST the platform manifest %s to the Intel RS platform registration endpoint",
+		manifestFingerprint(platformManifest)))
+	return metrics.StatusCodeMetric{Status: metrics.PlatformRebootNeeded}, nil, nil
+}
+
+func (d dryRunIntelClient) RegisterAddPackage(_ context.Context, _ mpmanagement.AddPackageRequest) (metrics.StatusCodeMetric, []byte, *intelservices.IntelResponse, error) {
+	d.recorder.record("POST the AddPackage request to the Intel RS add package endpoint")
+	return metrics.StatusCodeMetric{Status: metrics.AddPackageRebootNeeded}, nil, nil, nil
+}
+
+func (d dryRunIntelClient) RetrievePCK(ctx context.Context, platformInfo *sgxplatforminfo.SgxPcePlatformInfo) (metrics.StatusCodeMetric, *pckcerts.PckCertificates, *intelservices.IntelResponse, error) {
+	return d.intelClient.RetrievePCK(ctx, platformInfo)
+}
+
+func (d dryRunIntelClient) RetrievePckCrl(ctx context.Context, caType string) (*pckcerts.PckCrl, *intelservices.IntelResponse, error) {
+	return d.intelClient.RetrievePckCrl(ctx, caType)
+}
+
+func (d dryRunIntelClient) RetrieveTcbInfo(ctx context.Context, fmspc string) (*tcbinfo.TcbInfo, *intelservices.IntelResponse, error) {
+	return d.intelClient.RetrieveTcbInfo(ctx, fmspc)
+}
+
+// enableDryRun makes the checker run all the read paths while recording the UEFI writes and the Intel
+// registration requests instead of performing them; the checks report the status the registration would reach
+func (rc *DefaultRegistrationChecker) enableDryRun() {
+	recorder := &dryRunRecorder{log: rc.log}
+	openUefi := rc.openUefi
+	rc.openUefi = func() UefiAccessor {
+		return dryRunUefiAccessor{uefi: openUefi(), recorder: recorder}
+	}
+	rc.intelClient = dryRunIntelClient{intelClient: rc.intelClient, recorder: recorder}
+	rc.dryRun = recorder
+}
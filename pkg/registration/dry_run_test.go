@@ -0,0 +1,112 @@
+package registration
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	mpmanagement "github.com/opensovereigncloud/cc-intel-platform-registration/internal/pkg/mp_management"
+	"github.com/opensovereigncloud/cc-intel-platform-registration/pkg/metrics"
+	statestore "github.com/opensovereigncloud/cc-intel-platform-registration/pkg/state_store"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestDefaultRegistrationCheckerDryRun(t *testing.T) {
+	manifest := mpmanagement.PlatformManifest{0x02}
+	registerManifestAction := "POST the platform manifest " + manifestFingerprint(manifest) + " to the Intel RS platform registration endpoint"
+	completeAction := "set SgxRegistrationComplete in the UEFI variables"
+
+	cases := []struct {
+		msg             string
+		uefi            *fakeUefi
+		registration    statestore.Registration
+		expectedStatus  metrics.StatusCode
+		expectedActions []string
+		// expectedIntelRequests are the Intel requests of both checks, the PCK CRL being cached
+		expectedIntelRequests []string
+	}{
+		{
+			msg:             "platform registration",
+			uefi:            &fakeUefi{requestType: mpmanagement.RequestTypeRegistration, manifest: manifest},
+			expectedStatus:  metrics.PlatformRebootNeeded,
+			expectedActions: []string{registerManifestAction, completeAction},
+		},
+		{
+			msg:            "added CPU package registration",
+			uefi:           &fakeUefi{requestType: mpmanagement.RequestTypeAddPackage},
+			expectedStatus: metrics.AddPackageRebootNeeded,
+			expectedActions: []string{
+				"POST the AddPackage request to the Intel RS add package endpoint",
+				"write the Intel RS AddPackage response to the UEFI server response variable",
+				completeAction,
+			},
+		},
+		{
+			msg:             "TCB recovery registration",
+			uefi:            &fakeUefi{requestType: mpmanagement.RequestTypeRegistration, manifest: manifest},
+			registration:    statestore.Registration{WasRegistered: true, ManifestFingerprint: manifestFingerprint(mpmanagement.PlatformManifest{0x01})},
+			expectedStatus:  metrics.TcbRecoveryRegistered,
+			expectedActions: []string{registerManifestAction, completeAction},
+		},
+		{
+			msg:                   "registered platform, only the read paths",
+			uefi:                  newTestRegisteredUefi(),
+			expectedStatus:        metrics.PlatformDirectlyRegistered,
+			expectedIntelRequests: []string{"pck_retrieval", "pck_crl", "tcb_info", "pck_retrieval", "tcb_info"},
+		},
+	}
+
+	for _, c := range cases {
+		intelClient := newTestRegisteredIntelClient()
+		checker := NewRegistrationChecker(zap.NewNop(), func() UefiAccessor { return c.uefi }, fakePceInfoProvider{}, intelClient)
+		checker.restore(c.registration)
+		checker.enableDryRun()
+		registrations := testutil.ToFloat64(metrics.TcbRecoveryRegistrationsMetric)
+
+		// the same actions are listed at every check, as nothing was written
+		for range 2 {
+			checkResult, err := checker.Check(context.Background())
+			assert.NoError(t, err, c.msg)
+			assert.Equal(t, c.expectedStatus, checkResult.Status, c.msg)
+			assert.Equal(t, c.expectedActions, checkResult.DryRunActions, c.msg)
+		}
+
+		assert.Nil(t, c.uefi.serverResponse, c.msg)
+		assert.False(t, c.uefi.completed, c.msg)
+		assert.True(t, c.uefi.closed, c.msg)
+		assert.Equal(t, c.expectedIntelRequests, intelClient.calls, c.msg)
+		assert.Equal(t, c.registration, checker.registration, c.msg)
+		assert.Equal(t, registrations, testutil.ToFloat64(metrics.TcbRecoveryRegistrationsMetric), c.msg)
+	}
+}
+
+func TestRegistrationServiceDryRunDoesNotPersistState(t *testing.T) {
+	stateDir := t.TempDir()
+	uefi := &fakeUefi{requestType: mpmanagement.RequestTypeRegistration}
+	intelClient := newTestRegisteredIntelClient()
+	checker := NewRegistrationChecker(zap.NewNop(), func() UefiAccessor { return uefi }, fakePceInfoProvider{}, intelClient)
+	checker.enableDryRun()
+	registrationService := &RegistrationService{
+		checkTimeout:        time.Minute,
+		serverMetrics:       metrics.NewRegistrationServiceMetricsRegistry(zap.NewNop()),
+		registrationChecker: checker,
+		log:                 zap.NewNop(),
+		stateStore:          statestore.NewStore(stateDir, 10),
+		dryRun:              true,
+	}
+	registrationService.restoreState(checker)
+
+	checkResult := registrationService.CheckRegistrationStatus(context.Background())
+	assert.Equal(t, metrics.PlatformRebootNeeded, checkResult.Status)
+	assert.Len(t, checkResult.DryRunActions, 2)
+	assert.Empty(t, intelClient.calls)
+	assert.False(t, uefi.completed)
+
+	_, err := os.Stat(filepath.Join(stateDir, statestore.StateFileName))
+	require.ErrorIs(t, err, os.ErrNotExist)
+}
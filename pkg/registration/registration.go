@@ -2,116 +2,751 @@ package registration
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sync"
 	"time"
 
 	mpmanagement "github.com/opensovereigncloud/cc-intel-platform-registration/internal/pkg/mp_management"
-	sgxplatforminfo "github.com/opensovereigncloud/cc-intel-platform-registration/internal/pkg/sgx_platform_info"
+	"github.com/opensovereigncloud/cc-intel-platform-registration/pkg/constants"
 	intelservices "github.com/opensovereigncloud/cc-intel-platform-registration/pkg/intel_services"
 	"github.com/opensovereigncloud/cc-intel-platform-registration/pkg/metrics"
+	pckcerts "github.com/opensovereigncloud/cc-intel-platform-registration/pkg/pck_certs"
+	rebootsentinel "github.com/opensovereigncloud/cc-intel-platform-registration/pkg/reboot_sentinel"
+	statestore "github.com/opensovereigncloud/cc-intel-platform-registration/pkg/state_store"
+	tcbinfo "github.com/opensovereigncloud/cc-intel-platform-registration/pkg/tcb_info"
 	"go.uber.org/zap"
+	"golang.org/x/sync/singleflight"
 )
 
+// CheckResult is the outcome of a registration check
+type CheckResult struct {
+	metrics.StatusCodeMetric
+	// CheckedAt is when the check completed, set by the registration service
+	CheckedAt time.Time
+	// PreviousStatus is the status of the check before this one (Pending for the first check), set by the
+	// registration service, e.g. to publish the status transitions only
+	PreviousStatus metrics.StatusCode
+	// PckCertificates holds the PCK certificates retrieved from the PCS, if any
+	PckCertificates *pckcerts.PckCertificates
+	// PckCrl is the CRL the PCK certificates were checked against, if it could be retrieved
+	PckCrl *pckcerts.PckCrl
+	// TcbEvaluation is the TCB status of the platform, if the TCB Info of its FMSPC could be retrieved
+	TcbEvaluation *tcbinfo.TcbEvaluation
+	// RegistrationCompletedAt is when the registration of the platform was completed (or first seen completed),
+	// set while the platform waits for the reboot letting the BIOS consume the registration request
+	RegistrationCompletedAt time.Time
+	// ManifestFingerprint is the fingerprint of the platform manifest read during the check, if any
+	ManifestFingerprint string
+	// Registration is the registration history of the platform after the check
+	Registration statestore.Registration
+	// IntelResponses describes the responses of the Intel services requested during the check, e.g. to open an Intel support case
+	IntelResponses []*intelservices.IntelResponse
+	// DryRunActions lists the UEFI writes and the Intel registration requests skipped by a dry-run check
+	DryRunActions []string
+}
+
+// addIntelResponse records the response of an Intel request, if the request was sent
+func (c *CheckResult) addIntelResponse(intelResponse *intelservices.IntelResponse) {
+	if intelResponse != nil {
+		c.IntelResponses = append(c.IntelResponses, intelResponse)
+	}
+}
+
+// CheckPublisher publishes the result of the registration checks beyond the metrics, e.g. on the Kubernetes node
+type CheckPublisher interface {
+	Publish(ctx context.Context, checkResult CheckResult) error
+}
+
 // RegistrationChecker is an interface to facilitate tests
 type RegistrationChecker interface {
-	Check() (metrics.StatusCodeMetric, error)
+	// Check determines the registration status; the Intel requests are aborted as soon as the context is done
+	Check(ctx context.Context) (CheckResult, error)
 }
 
-func NewRegistrationChecker(logger *zap.Logger) *DefaultRegistrationChecker {
+// NewRegistrationChecker creates a checker walking the registration decision tree on top of the given
+// UEFI accessor, PCE info provider and Intel client
+func NewRegistrationChecker(logger *zap.Logger, openUefi UefiAccessorFactory, pceInfoProvider PceInfoProvider, intelClient IntelClient) *DefaultRegistrationChecker {
 	return &DefaultRegistrationChecker{
-		log: logger,
+		log:             logger,
+		openUefi:        openUefi,
+		pceInfoProvider: pceInfoProvider,
+		intelClient:     intelClient,
+		pckCrls:         map[string]*cachedPckCrl{},
 	}
 }
 
 type DefaultRegistrationChecker struct {
-	log *zap.Logger
+	log             *zap.Logger
+	openUefi        UefiAccessorFactory
+	pceInfoProvider PceInfoProvider
+	intelClient     IntelClient
+	// pckCrls caches the last retrieved CRL per PCK CA type
+	pckCrls map[string]*cachedPckCrl
+	// registration is the registration history of the platform, persisted across restarts by the service.
+	// A pending manifest differing from the registered one reveals a TCB recovery or an SGX reset.
+	registration statestore.Registration
+	// dryRun records the actions skipped by the dry-run checks; nil unless the dry run is enabled
+	dryRun *dryRunRecorder
 }
 
-func (rc *DefaultRegistrationChecker) Check() (metrics.StatusCodeMetric, error) {
-	mp := mpmanagement.NewMPManagement()
-	defer mp.Close()
+type cachedPckCrl struct {
+	pckCrl      *pckcerts.PckCrl
+	retrievedAt time.Time
+}
 
-	intelService := intelservices.NewIntelService(rc.log)
+func newCheckResult(status metrics.StatusCode) CheckResult {
+	return CheckResult{StatusCodeMetric: metrics.StatusCodeMetric{Status: status}}
+}
+
+func (rc *DefaultRegistrationChecker) Check(ctx context.Context) (CheckResult, error) {
+	registration := rc.registration
+	checkResult, err := rc.check(ctx)
+	checkResult.Registration = rc.registration
+	if rc.dryRun != nil {
+		// the registration history only reflects what was actually registered
+		rc.registration = registration
+		checkResult.DryRunActions = rc.dryRun.take()
+	}
+	return checkResult, err
+}
+
+// restore seeds the registration history of the platform, e.g. with the one persisted before a restart
+func (rc *DefaultRegistrationChecker) restore(registration statestore.Registration) {
+	rc.registration = registration
+}
+
+func (rc *DefaultRegistrationChecker) check(ctx context.Context) (CheckResult, error) {
+	mp := rc.openUefi()
+	defer mp.Close()
 
 	isMachineRegistered, err := mp.IsMachineRegistered()
 	if err != nil {
-		return metrics.StatusCodeMetric{Status: metrics.SgxUefiUnavailable}, err
+		return newCheckResult(metrics.SgxUefiUnavailable), err
 	}
 
 	if !isMachineRegistered {
+		requestType, requestTypeErr := mp.GetPendingRequestType()
+		if requestTypeErr != nil {
+			return newCheckResult(metrics.SgxUefiUnavailable), requestTypeErr
+		}
+		if requestType == mpmanagement.RequestTypeAddPackage {
+			return rc.registerAddPackage(ctx, mp)
+		}
+
+		// the manifest is only readable while the platform is not registered; its fingerprint is recorded
+		// when it is registered, and a registered platform publishing another manifest went through a TCB
+		// recovery or an SGX reset
 		plaformManifest, platManErr := mp.GetPlatformManifest()
 		if platManErr != nil {
-			return metrics.StatusCodeMetric{Status: metrics.SgxUefiUnavailable}, platManErr
+			return newCheckResult(metrics.SgxUefiUnavailable), platManErr
 		}
-		metric, regErr := intelService.RegisterPlatform(plaformManifest)
+		tcbRecovery := rc.registration.WasRegistered && manifestFingerprint(plaformManifest) != rc.registration.ManifestFingerprint
+		return rc.registerPlatform(ctx, mp, plaformManifest, tcbRecovery)
+	}
+	rc.registration.WasRegistered = true
 
-		// registration was successful
-		if metric.Status == metrics.PlatformRebootNeeded {
-			completeErr := mp.CompleteMachineRegistrationStatus()
-			if completeErr != nil {
-				return metrics.StatusCodeMetric{Status: metrics.UefiPersistFailed}, completeErr
-			}
+	// the BIOS removes the registration request at the first reboot following the registration. The request
+	// itself cannot be read once the platform is registered, the MP management library only returns its type.
+	requestType, err := mp.GetPendingRequestType()
+	if err != nil {
+		return newCheckResult(metrics.SgxUefiUnavailable), err
+	}
+	if requestType != mpmanagement.RequestTypeNone {
+		return rc.pendingReboot(requestType), nil
+	}
+	rc.registration.CompletedAt = time.Time{}
+	rc.registration.TcbRecovery = false
+
+	platformInfo, err := rc.pceInfoProvider.GetSgxPcePlatformInfo()
+	if err != nil {
+		return newCheckResult(metrics.RetryNeeded), err
+	}
+
+	metric, pckCertificates, intelResponse, err := rc.intelClient.RetrievePCK(ctx, platformInfo)
+	checkResult := CheckResult{StatusCodeMetric: metric, PckCertificates: pckCertificates}
+	checkResult.addIntelResponse(intelResponse)
+	if pckCertificates != nil && ctx.Err() == nil {
+		rc.checkRevocation(ctx, &checkResult)
+		rc.evaluateTcb(ctx, &checkResult)
+	}
+	return checkResult, err
+}
+
+// checkRevocation checks the PCK certificates of the platform against the CRL of their PCK CA and reports
+// a revoked platform. The registration status is kept when the CRL cannot be retrieved.
+func (rc *DefaultRegistrationChecker) checkRevocation(ctx context.Context, checkResult *CheckResult) {
+	caType, err := checkResult.PckCertificates.CrlCaType()
+	if err != nil {
+		rc.log.Warn("unable to check the revocation of the PCK certificates", zap.Error(err))
+		return
+	}
+	pckCrl := rc.getPckCrl(ctx, caType, checkResult)
+	if pckCrl == nil {
+		return
+	}
+	checkResult.PckCrl = pckCrl
+	// an outdated CRL still lists the revocations it knows of, it is used rather than skipping the check
+	if pckCrl.Outdated(time.Now()) {
+		rc.log.Warn("PCK CRL is outdated, the certificates revoked since its nextUpdate are not detected",
+			zap.String("ca", caType), zap.Time("nextUpdate", pckCrl.RevocationList.NextUpdate))
+	}
+
+	if revokedTcbms := pckCrl.RevokedTcbms(checkResult.PckCertificates); len(revokedTcbms) > 0 {
+		rc.log.Error("PCK certificates of the platform are revoked", zap.String("ca", caType), zap.Strings("tcbms", revokedTcbms))
+		checkResult.StatusCodeMetric = metrics.StatusCodeMetric{Status: metrics.PlatformRevoked}
+	}
+}
+
+// getPckCrl returns the CRL of the PCK CA, downloaded again once its nextUpdate is reached or at least every
+// PckCrlRefreshInterval. The last retrieved CRL is used when the download fails, or nil if there is none.
+func (rc *DefaultRegistrationChecker) getPckCrl(ctx context.Context, caType string, checkResult *CheckResult) *pckcerts.PckCrl {
+	now := time.Now()
+	cached, ok := rc.pckCrls[caType]
+	if ok && now.Before(cached.pckCrl.RevocationList.NextUpdate) && now.Sub(cached.retrievedAt) < constants.PckCrlRefreshInterval {
+		return cached.pckCrl
+	}
+
+	pckCrl, intelResponse, err := rc.intelClient.RetrievePckCrl(ctx, caType)
+	checkResult.addIntelResponse(intelResponse)
+	if err != nil {
+		if ok {
+			rc.log.Warn("unable to retrieve the PCK CRL, using the last retrieved one",
+				zap.String("ca", caType), zap.Time("nextUpdate", cached.pckCrl.RevocationList.NextUpdate), zap.Error(err))
+			return cached.pckCrl
 		}
-		return metric, regErr
+		rc.log.Warn("unable to retrieve the PCK CRL", zap.String("ca", caType), zap.Error(err))
+		return nil
+	}
+	rc.pckCrls[caType] = &cachedPckCrl{pckCrl: pckCrl, retrievedAt: now}
+	return pckCrl
+}
 
+// evaluateTcb evaluates the TCB status of the platform against the TCB Info of its FMSPC.
+// The TCB status does not affect the registration status, so a failure is only logged.
+func (rc *DefaultRegistrationChecker) evaluateTcb(ctx context.Context, checkResult *CheckResult) {
+	fmspc := checkResult.PckCertificates.Extensions.Fmspc
+	tcbInfo, intelResponse, err := rc.intelClient.RetrieveTcbInfo(ctx, fmspc)
+	checkResult.addIntelResponse(intelResponse)
+	if err != nil {
+		rc.log.Warn("unable to retrieve the TCB Info of the platform", zap.String("fmspc", fmspc), zap.Error(err))
+		return
 	}
+	checkResult.TcbEvaluation = tcbInfo.Evaluate(checkResult.PckCertificates)
+}
 
-	platformInfo, err := sgxplatforminfo.GetSgxPcePlatformInfo()
+// registerAddPackage registers a CPU package added to or replaced in an already registered platform.
+// The Intel RS response is written back to the UEFI for the BIOS to complete the process at the next boot.
+func (rc *DefaultRegistrationChecker) registerAddPackage(ctx context.Context, mp UefiAccessor) (CheckResult, error) {
+	addPackageRequest, err := mp.GetAddPackageRequest()
 	if err != nil {
-		return metrics.StatusCodeMetric{Status: metrics.RetryNeeded}, err
+		return newCheckResult(metrics.SgxUefiUnavailable), err
 	}
+	rc.log.Info("AddPackage request pending, registering the added CPU package")
 
-	metric, err := intelService.RetrievePCK(platformInfo)
-	return metric, err
+	metric, response, intelResponse, err := rc.intelClient.RegisterAddPackage(ctx, addPackageRequest)
+	checkResult := CheckResult{StatusCodeMetric: metric}
+	checkResult.addIntelResponse(intelResponse)
+	if metric.Status != metrics.AddPackageRebootNeeded {
+		return checkResult, err
+	}
+
+	if err := mp.SetServerResponse(response); err != nil {
+		checkResult.StatusCodeMetric = metrics.StatusCodeMetric{Status: metrics.UefiPersistFailed}
+		return checkResult, err
+	}
+	if err := mp.CompleteMachineRegistrationStatus(); err != nil {
+		checkResult.StatusCodeMetric = metrics.StatusCodeMetric{Status: metrics.UefiPersistFailed}
+		return checkResult, err
+	}
+	rc.registration.CompletedAt = time.Now()
+	checkResult.RegistrationCompletedAt = rc.registration.CompletedAt
+	return checkResult, nil
+}
+
+// registerPlatform registers the platform manifest with the Intel RS and completes the registration on success.
+// The registration of a new manifest of a previously registered platform is reported as a TCB recovery registration.
+func (rc *DefaultRegistrationChecker) registerPlatform(ctx context.Context, mp UefiAccessor, platformManifest mpmanagement.PlatformManifest, tcbRecovery bool) (CheckResult, error) {
+	fingerprint := manifestFingerprint(platformManifest)
+	if tcbRecovery {
+		rc.log.Info("new platform manifest of a registered platform, registering it after a TCB recovery or an SGX reset",
+			zap.String("manifestFingerprint", fingerprint), zap.String("registeredManifestFingerprint", rc.registration.ManifestFingerprint))
+	}
+	metric, intelResponse, err := rc.intelClient.RegisterPlatform(ctx, platformManifest)
+	checkResult := CheckResult{StatusCodeMetric: metric, ManifestFingerprint: fingerprint}
+	checkResult.addIntelResponse(intelResponse)
+	if metric.Status != metrics.PlatformRebootNeeded {
+		return checkResult, err
+	}
+
+	if err := mp.CompleteMachineRegistrationStatus(); err != nil {
+		checkResult.StatusCodeMetric = metrics.StatusCodeMetric{Status: metrics.UefiPersistFailed}
+		return checkResult, err
+	}
+	rc.registration.WasRegistered = true
+	rc.registration.ManifestFingerprint = fingerprint
+	rc.registration.TcbRecovery = tcbRecovery
+	rc.registration.CompletedAt = time.Now()
+	checkResult.RegistrationCompletedAt = rc.registration.CompletedAt
+	if tcbRecovery {
+		if rc.dryRun == nil {
+			metrics.IncTcbRecoveryRegistrations()
+		}
+		checkResult.StatusCodeMetric = metrics.StatusCodeMetric{Status: metrics.TcbRecoveryRegistered}
+	}
+	return checkResult, nil
+}
+
+// manifestFingerprint identifies a platform manifest by its SHA-256 digest
+func manifestFingerprint(platformManifest mpmanagement.PlatformManifest) string {
+	digest := sha256.Sum256(platformManifest)
+	return hex.EncodeToString(digest[:])
+}
+
+// pendingReboot reports a completed registration whose request was not consumed by the BIOS yet, i.e. a
+// platform waiting for a reboot. When the registration was completed before the service started, the
+// completion time is the first time the pending reboot was seen.
+func (rc *DefaultRegistrationChecker) pendingReboot(requestType mpmanagement.RequestType) CheckResult {
+	if rc.registration.CompletedAt.IsZero() {
+		rc.registration.CompletedAt = time.Now()
+	}
+	status := metrics.PlatformRebootNeeded
+	if requestType == mpmanagement.RequestTypeAddPackage {
+		status = metrics.AddPackageRebootNeeded
+	} else if rc.registration.TcbRecovery {
+		status = metrics.TcbRecoveryRegistered
+	}
+	rc.log.Info("registration completed, waiting for a reboot",
+		zap.String("requestType", requestType.String()),
+		zap.Time("registrationCompletedAt", rc.registration.CompletedAt),
+		zap.Duration("sinceRegistration", time.Since(rc.registration.CompletedAt)))
+	checkResult := newCheckResult(status)
+	checkResult.RegistrationCompletedAt = rc.registration.CompletedAt
+	return checkResult
+}
+
+// CheckSummary is the JSON summary of a registration check, returned by the admin check endpoint and
+// written by the one-shot check
+type CheckSummary struct {
+	Status                  int        `json:"status"`
+	Description             string     `json:"description"`
+	HttpStatusCode          string     `json:"httpStatusCode,omitempty"`
+	IntelErrorCode          string     `json:"intelErrorCode,omitempty"`
+	IntelRequestIDs         []string   `json:"intelRequestIds,omitempty"`
+	TcbStatus               string     `json:"tcbStatus,omitempty"`
+	RegistrationCompletedAt *time.Time `json:"registrationCompletedAt,omitempty"`
+	DryRunActions           []string   `json:"dryRunActions,omitempty"`
+}
+
+// NewCheckSummary summarizes the result of a registration check
+func NewCheckSummary(checkResult CheckResult) CheckSummary {
+	summary := CheckSummary{
+		Status:          int(checkResult.Status),
+		Description:     checkResult.Status.String(),
+		HttpStatusCode:  checkResult.HttpStatusCode,
+		IntelErrorCode:  checkResult.IntelError,
+		IntelRequestIDs: checkResult.IntelRequestIDs(),
+		DryRunActions:   checkResult.DryRunActions,
+	}
+	if checkResult.TcbEvaluation != nil {
+		summary.TcbStatus = string(checkResult.TcbEvaluation.Status)
+	}
+	if !checkResult.RegistrationCompletedAt.IsZero() {
+		summary.RegistrationCompletedAt = &checkResult.RegistrationCompletedAt
+	}
+	return summary
+}
+
+// IntelRequestIDs returns the Request-ID of the Intel responses of the check
+func (c CheckResult) IntelRequestIDs() []string {
+	var requestIDs []string
+	for _, intelResponse := range c.IntelResponses {
+		if intelResponse.RequestID != "" {
+			requestIDs = append(requestIDs, intelResponse.RequestID)
+		}
+	}
+	return requestIDs
 }
 
 type RegistrationService struct {
-	intervalDuration    time.Duration
+	// schedulePolicy sets the delay before the next check according to the status of the last one
+	schedulePolicy SchedulePolicy
+	// checkTimeout bounds the duration of a registration check, including all its Intel requests
+	checkTimeout        time.Duration
 	serverMetrics       *metrics.RegistrationServiceMetricsRegistry
 	log                 *zap.Logger
 	registrationChecker RegistrationChecker
+	// stateStore persists the registration state across restarts; nil when persistence is disabled
+	stateStore *statestore.Store
+	state      *statestore.State
+	// dryRun disables the UEFI writes, the Intel registration requests, the persistence of the state and
+	// the updates of the reboot sentinel
+	dryRun bool
+	// rebootSentinel signals the pending reboots to a reboot daemon like kured; nil when disabled
+	rebootSentinel *rebootsentinel.Sentinel
+	// publishers publish the result of every completed check
+	publishers []CheckPublisher
+
+	lastCheckResultMutex sync.RWMutex
+	lastCheckResult      *CheckResult
+
+	// checkGroup joins the concurrent registration checks, e.g. a triggered check and a scheduled one
+	checkGroup singleflight.Group
+	// runCtx is the context of Run, used by the triggered checks; nil until the service runs
+	runCtxMutex sync.Mutex
+	runCtx      context.Context
+
+	// scheduleMutex guards the schedule of the next check, updated by every check including the triggered ones
+	scheduleMutex       sync.Mutex
+	consecutiveFailures int
+	nextCheckAt         time.Time
+	// rescheduled signals Run that a check updated the schedule; nil when the service is not created
+	// through NewRegistrationService
+	rescheduled chan struct{}
 }
 
-func (r *RegistrationService) Run(ctx context.Context) error {
-	err := r.serverMetrics.SetServiceStatusCodeToPending()
+// ErrServiceNotRunning is returned when a check is triggered while the registration service is not running
+var ErrServiceNotRunning = errors.New("registration service is not running")
 
-	if err != nil {
+// registrationCheckKey identifies the registration check in the checkGroup
+const registrationCheckKey = "registration-check"
+
+// ServiceConfig configures the registration service
+type ServiceConfig struct {
+	// Schedule sets the delay before the next registration check according to the status of the last one
+	Schedule SchedulePolicy
+	// CheckTimeout bounds the duration of a registration check, including all its Intel requests
+	CheckTimeout       time.Duration
+	IntelServiceConfig intelservices.Config
+	// StateDir is the directory the registration state is persisted in; the state is not persisted when empty
+	StateDir string
+	// StateMaxRecords bounds the number of checks and status transitions kept in the persisted state
+	StateMaxRecords int
+	// DryRun runs the read paths only: the UEFI writes and the Intel registration requests are listed instead
+	// of being performed, and the checks report the status the registration would reach. The persisted state
+	// is read but never written.
+	DryRun bool
+	// RebootSentinelPath is the file written while the platform waits for a reboot, e.g. /var/run/reboot-required
+	// watched by kured; no file is written when empty
+	RebootSentinelPath string
+	// RebootSentinelReason is the content of the reboot sentinel file
+	RebootSentinelReason string
+	// Publishers publish the result of every completed check, e.g. on the Kubernetes node; they are skipped
+	// by the dry-run checks
+	Publishers []CheckPublisher
+}
+
+func (r *RegistrationService) Run(ctx context.Context) error {
+	// the metrics are seeded with the last check persisted before a restart, if any
+	if lastCheckResult := r.LastCheckResult(); lastCheckResult != nil {
+		r.log.Info("Registration state restored",
+			zap.String("status", lastCheckResult.Status.String()),
+			zap.Time("registrationCompletedAt", lastCheckResult.RegistrationCompletedAt))
+		if err := r.serverMetrics.UpdateServiceStatusCodeMetric(lastCheckResult.StatusCodeMetric); err != nil {
+			// e.g. a state persisted by an older release; the first check replaces it
+			r.log.Error("unable to restore the registration service status code metric", zap.Error(err))
+			if err := r.serverMetrics.SetServiceStatusCodeToPending(); err != nil {
+				return err
+			}
+		}
+		metrics.SetRegistrationCompleted(lastCheckResult.RegistrationCompletedAt)
+	} else if err := r.serverMetrics.SetServiceStatusCodeToPending(); err != nil {
 		return err
 	}
 
+	r.runCtxMutex.Lock()
+	r.runCtx = ctx
+	r.runCtxMutex.Unlock()
+
 	// first check
-	r.CheckRegistrationStatus()
+	<-r.runCheck(ctx)
 
-	ticker := time.NewTicker(r.intervalDuration)
-	defer ticker.Stop()
+	timer := time.NewTimer(time.Until(r.nextCheck()))
+	defer timer.Stop()
 
 	for {
 		select {
-		case <-ticker.C:
-			r.CheckRegistrationStatus()
+		case <-timer.C:
+			<-r.runCheck(ctx)
+			timer.Reset(time.Until(r.nextCheck()))
+		case <-r.rescheduled:
+			// a triggered check completed, the next check follows its status
+			if !timer.Stop() {
+				select {
+				case <-timer.C:
+				default:
+				}
+			}
+			timer.Reset(time.Until(r.nextCheck()))
 		case <-ctx.Done():
 			return nil
 		}
 	}
 }
 
-func (r *RegistrationService) CheckRegistrationStatus() {
-	statusCodeMetric, err := r.registrationChecker.Check()
+// runCheck starts a registration check, or joins the one in progress
+func (r *RegistrationService) runCheck(ctx context.Context) <-chan singleflight.Result {
+	return r.checkGroup.DoChan(registrationCheckKey, func() (any, error) {
+		return r.CheckRegistrationStatus(ctx), nil
+	})
+}
+
+// TriggerCheck runs an immediate registration check, or joins the one in progress, and returns its result.
+// The check runs in the context of the service, so it completes and is published even if ctx is done first,
+// in which case the error of ctx is returned.
+func (r *RegistrationService) TriggerCheck(ctx context.Context) (CheckResult, error) {
+	r.runCtxMutex.Lock()
+	runCtx := r.runCtx
+	r.runCtxMutex.Unlock()
+	if runCtx == nil || runCtx.Err() != nil {
+		return CheckResult{}, ErrServiceNotRunning
+	}
+
+	select {
+	case result := <-r.runCheck(runCtx):
+		return result.Val.(CheckResult), nil
+	case <-ctx.Done():
+		return CheckResult{}, ctx.Err()
+	}
+}
+
+// CheckRegistrationStatus runs a registration check bounded by the check timeout, publishes and returns its result.
+// A check canceled through the context (e.g. on shutdown) is reported as CheckCanceled.
+func (r *RegistrationService) CheckRegistrationStatus(ctx context.Context) CheckResult {
+	checkCtx, cancel := context.WithTimeout(ctx, r.checkTimeout)
+	defer cancel()
+
+	checkResult, err := r.registrationChecker.Check(checkCtx)
+	checkResult.CheckedAt = time.Now()
 	if err != nil {
-		r.log.Error("unable to get the registration status", zap.Error(err))
+		r.log.Error("unable to get the registration status", zap.Error(err), zap.Strings("intelRequestIds", checkResult.IntelRequestIDs()))
 	}
-	r.log.Debug("Registration check completed", zap.String("status", statusCodeMetric.Status.String()))
-	err = r.serverMetrics.UpdateServiceStatusCodeMetric(statusCodeMetric)
+	nextCheckAt := r.scheduleNextCheck(checkResult.Status)
+	r.log.Debug("Registration check completed",
+		zap.String("status", checkResult.Status.String()),
+		zap.Time("nextCheckAt", nextCheckAt))
+	err = r.serverMetrics.UpdateServiceStatusCodeMetric(checkResult.StatusCodeMetric)
 	if err != nil {
 		r.log.Error("unable to update registration service status code metric", zap.Error(err))
 	}
+
+	if checkResult.PckCertificates != nil {
+		tcbms := checkResult.PckCertificates.AvailableTcbms()
+		// the FMSPC is decoded from the PCK certificate, as a caching service may omit the FMSPC header
+		fmspc := ""
+		if checkResult.PckCertificates.Extensions != nil {
+			fmspc = checkResult.PckCertificates.Extensions.Fmspc
+		}
+		r.log.Info("PCK certificates retrieved",
+			zap.Strings("tcbms", tcbms),
+			zap.String("fmspc", fmspc),
+			zap.String("caType", checkResult.PckCertificates.CaType))
+		metrics.SetPckCertificateTcbLevels(tcbms)
+
+		if extensions := checkResult.PckCertificates.Extensions; extensions != nil {
+			logFields := []zap.Field{
+				zap.String("fmspc", extensions.Fmspc),
+				zap.String("sgxType", extensions.SgxType.String()),
+				zap.String("pceId", extensions.PceID),
+			}
+			if extensions.Configuration != nil {
+				logFields = append(logFields,
+					zap.Bool("cachedKeys", extensions.Configuration.CachedKeys),
+					zap.Bool("dynamicPlatform", extensions.Configuration.DynamicPlatform),
+					zap.Bool("smtEnabled", extensions.Configuration.SmtEnabled))
+			}
+			r.log.Info("SGX extension of the PCK certificates decoded", logFields...)
+			metrics.SetSgxPlatformInfo(extensions.Fmspc, extensions.SgxType.String())
+		}
+	}
+
+	metrics.SetRegistrationCompleted(checkResult.RegistrationCompletedAt)
+	r.updateRebootSentinel(&checkResult)
+
+	if pckCrl := checkResult.PckCrl; pckCrl != nil {
+		metrics.SetPckCrl(pckCrl.CaType, pckCrl.RevocationList.ThisUpdate, pckCrl.RevocationList.NextUpdate)
+	}
+
+	if evaluation := checkResult.TcbEvaluation; evaluation != nil {
+		r.log.Info("TCB status of the platform evaluated",
+			zap.String("tcbStatus", string(evaluation.Status)),
+			zap.String("tcbm", evaluation.Tcbm),
+			zap.Strings("advisoryIds", evaluation.AdvisoryIDs),
+			zap.Int("tcbEvaluationDataNumber", evaluation.TcbEvaluationDataNumber),
+			zap.Time("nextUpdate", evaluation.NextUpdate))
+		metrics.SetTcbEvaluation(string(evaluation.Status), evaluation.Tcbm, evaluation.AdvisoryIDs, evaluation.TcbEvaluationDataNumber)
+	}
+
+	r.lastCheckResultMutex.Lock()
+	checkResult.PreviousStatus = metrics.Pending
+	if r.lastCheckResult != nil {
+		checkResult.PreviousStatus = r.lastCheckResult.Status
+	}
+	r.lastCheckResult = &checkResult
+	r.lastCheckResultMutex.Unlock()
+
+	r.persistState(ctx, checkResult)
+	r.publish(ctx, checkResult)
+	return checkResult
 }
 
-func NewRegistrationService(logger *zap.Logger, intervalDuration time.Duration) *RegistrationService {
+// publish hands the check result to the publishers. A check interrupted by the shutdown is not published,
+// nor a dry-run check, whose status is synthetic.
+func (r *RegistrationService) publish(ctx context.Context, checkResult CheckResult) {
+	if ctx.Err() != nil || r.dryRun {
+		return
+	}
+	for _, publisher := range r.publishers {
+		publishCtx, cancel := context.WithTimeout(ctx, constants.PublishTimeout)
+		err := publisher.Publish(publishCtx, checkResult)
+		cancel()
+		if err != nil {
+			r.log.Error("unable to publish the registration check", zap.String("publisher", fmt.Sprintf("%T", publisher)), zap.Error(err))
+		}
+	}
+}
+
+// updateRebootSentinel writes the reboot sentinel while the platform waits for a reboot, and removes it once the
+// platform is registered. A dry-run check only lists the update.
+func (r *RegistrationService) updateRebootSentinel(checkResult *CheckResult) {
+	if r.rebootSentinel == nil {
+		return
+	}
+	update := r.rebootSentinel.Update
+	if r.dryRun {
+		update = r.rebootSentinel.Plan
+	}
+	action, err := update(checkResult.Status)
+	if err != nil {
+		r.log.Error("unable to update the reboot sentinel", zap.String("path", r.rebootSentinel.Path()), zap.Error(err))
+		return
+	}
+	if action == rebootsentinel.ActionNone {
+		return
+	}
+	if r.dryRun {
+		verb := "write"
+		if action == rebootsentinel.ActionRemoved {
+			verb = "remove"
+		}
+		dryRunAction := fmt.Sprintf("%s the reboot sentinel %s", verb, r.rebootSentinel.Path())
+		r.log.Info("dry run, action skipped", zap.String("action", dryRunAction))
+		checkResult.DryRunActions = append(checkResult.DryRunActions, dryRunAction)
+		return
+	}
+	r.log.Info("Reboot sentinel updated", zap.String("path", r.rebootSentinel.Path()), zap.String("action", string(action)))
+}
+
+// scheduleNextCheck schedules the check following a check of the given status and returns when it is due
+func (r *RegistrationService) scheduleNextCheck(status metrics.StatusCode) time.Time {
+	r.scheduleMutex.Lock()
+	if isFailureStatus(status) {
+		r.consecutiveFailures++
+	} else {
+		r.consecutiveFailures = 0
+	}
+	r.nextCheckAt = time.Now().Add(r.schedulePolicy.nextDelay(status, r.consecutiveFailures))
+	nextCheckAt := r.nextCheckAt
+	r.scheduleMutex.Unlock()
+
+	metrics.SetNextCheck(nextCheckAt)
+	select {
+	case r.rescheduled <- struct{}{}:
+	default:
+	}
+	return nextCheckAt
+}
+
+// nextCheck returns when the next registration check is scheduled, or the zero time before the first check
+func (r *RegistrationService) nextCheck() time.Time {
+	r.scheduleMutex.Lock()
+	defer r.scheduleMutex.Unlock()
+	return r.nextCheckAt
+}
+
+// persistState records the check in the persisted registration state. A check interrupted by the shutdown
+// is not recorded, so the status restored at the next start is the one of the last completed check, nor a
+// check lacking the HTTP status code or Intel error code its status requires, which could not be restored.
+func (r *RegistrationService) persistState(ctx context.Context, checkResult CheckResult) {
+	if r.stateStore == nil || r.dryRun {
+		return
+	}
+	r.state.Registration = checkResult.Registration
+	if err := checkResult.StatusCodeMetric.Validate(); err != nil {
+		r.log.Error("unable to record the registration check in the registration state", zap.Error(err))
+	} else if ctx.Err() == nil {
+		r.state.Record(statestore.CheckRecord{
+			Time:                checkResult.CheckedAt,
+			Status:              checkResult.Status,
+			HttpStatusCode:      checkResult.HttpStatusCode,
+			IntelError:          checkResult.IntelError,
+			IntelRequestIDs:     checkResult.IntelRequestIDs(),
+			ManifestFingerprint: checkResult.ManifestFingerprint,
+		}, r.stateStore.MaxRecords())
+	}
+	if err := r.stateStore.Save(r.state); err != nil {
+		r.log.Error("unable to persist the registration state", zap.Error(err))
+	}
+}
+
+// restoreState loads the persisted registration state, to seed the registration history of the checker and
+// the last check result. A state that cannot be loaded is logged and replaced at the next check.
+func (r *RegistrationService) restoreState(checker *DefaultRegistrationChecker) {
+	state, err := r.stateStore.Load()
+	if err != nil {
+		r.log.Error("unable to load the registration state, starting afresh", zap.Error(err))
+		state = &statestore.State{}
+	}
+	r.state = state
+	checker.restore(state.Registration)
+
+	if lastCheck := state.LastCheck(); lastCheck != nil {
+		r.lastCheckResult = &CheckResult{
+			StatusCodeMetric: metrics.StatusCodeMetric{
+				Status:         lastCheck.Status,
+				HttpStatusCode: lastCheck.HttpStatusCode,
+				IntelError:     lastCheck.IntelError,
+			},
+			CheckedAt:               lastCheck.Time,
+			RegistrationCompletedAt: state.Registration.CompletedAt,
+			ManifestFingerprint:     lastCheck.ManifestFingerprint,
+			Registration:            state.Registration,
+		}
+	}
+}
+
+// LastCheckResult returns the result of the last registration check, or nil if no check completed yet
+func (r *RegistrationService) LastCheckResult() *CheckResult {
+	r.lastCheckResultMutex.RLock()
+	defer r.lastCheckResultMutex.RUnlock()
+	return r.lastCheckResult
+}
+
+func NewRegistrationService(logger *zap.Logger, config ServiceConfig) *RegistrationService {
+	registrationChecker := NewRegistrationChecker(logger, newMPManagementUefiAccessor, sgxPceInfoProvider{},
+		intelservices.NewIntelService(logger, config.IntelServiceConfig))
 	registrationService := &RegistrationService{
 		serverMetrics:       metrics.NewRegistrationServiceMetricsRegistry(logger),
-		registrationChecker: NewRegistrationChecker(logger),
+		registrationChecker: registrationChecker,
 		log:                 logger,
-		intervalDuration:    intervalDuration,
+		schedulePolicy:      config.Schedule,
+		checkTimeout:        config.CheckTimeout,
+		rescheduled:         make(chan struct{}, 1),
+		dryRun:              config.DryRun,
+		publishers:          config.Publishers,
+	}
+	if config.RebootSentinelPath != "" {
+		registrationService.rebootSentinel = rebootsentinel.NewSentinel(config.RebootSentinelPath, config.RebootSentinelReason)
+	}
+	if config.DryRun {
+		logger.Warn("dry run enabled, the UEFI writes and the Intel registration requests are skipped and the state is not persisted")
+		registrationChecker.enableDryRun()
+	}
+	if config.StateDir != "" {
+		registrationService.stateStore = statestore.NewStore(config.StateDir, config.StateMaxRecords)
+		registrationService.restoreState(registrationChecker)
 	}
 
 	return registrationService
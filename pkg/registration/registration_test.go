@@ -3,6 +3,7 @@ package registration
 import (
 	"context"
 	"fmt"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -12,7 +13,9 @@ import (
 	"go.uber.org/zap/zapcore"
 	"go.uber.org/zap/zaptest/observer"
 
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 type TestRegistrationChecker struct {
@@ -20,13 +23,61 @@ type TestRegistrationChecker struct {
 	counter     int
 }
 
-func (rc *TestRegistrationChecker) Check() (metrics.StatusCodeMetric, error) {
+func (rc *TestRegistrationChecker) Check(ctx context.Context) (CheckResult, error) {
 	if rc.counter == len(rc.metricSteps) {
 		rc.counter = 0
 	}
 	currentMetric := rc.metricSteps[rc.counter]
 	rc.counter++
-	return metrics.StatusCodeMetric{Status: currentMetric}, nil
+	return CheckResult{StatusCodeMetric: metrics.StatusCodeMetric{Status: currentMetric}}, nil
+}
+
+// hangingRegistrationChecker blocks like a check waiting for an unresponsive Intel service, until its context is done
+type hangingRegistrationChecker struct{}
+
+func (rc *hangingRegistrationChecker) Check(ctx context.Context) (CheckResult, error) {
+	<-ctx.Done()
+	return CheckResult{StatusCodeMetric: metrics.StatusCodeMetric{Status: metrics.CheckCanceled}}, ctx.Err()
+}
+
+func TestRegistrationServiceAbortsHungCheck(t *testing.T) {
+	cases := []struct {
+		msg          string
+		checkTimeout time.Duration
+		runTimeout   time.Duration
+	}{
+		{
+			msg:          "shutdown aborts a hung check",
+			checkTimeout: time.Hour,
+			runTimeout:   50 * time.Millisecond,
+		},
+		{
+			msg:          "check timeout aborts a hung check",
+			checkTimeout: 50 * time.Millisecond,
+			runTimeout:   200 * time.Millisecond,
+		},
+	}
+
+	for _, c := range cases {
+		registrationService := &RegistrationService{
+			schedulePolicy:      DefaultSchedulePolicy(),
+			checkTimeout:        c.checkTimeout,
+			serverMetrics:       metrics.NewRegistrationServiceMetricsRegistry(zap.NewNop()),
+			registrationChecker: &hangingRegistrationChecker{},
+			log:                 zap.NewNop(),
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), c.runTimeout)
+		start := time.Now()
+		err := registrationService.Run(ctx)
+		cancel()
+
+		assert.NoError(t, err, c.msg)
+		assert.Less(t, time.Since(start), 5*time.Second, c.msg)
+		if assert.NotNil(t, registrationService.LastCheckResult(), c.msg) {
+			assert.Equal(t, metrics.CheckCanceled, registrationService.LastCheckResult().Status, c.msg)
+		}
+	}
 }
 
 func TestRegistrationServiceRun(t *testing.T) {
@@ -105,7 +156,14 @@ func TestRegistrationServiceRun(t *testing.T) {
 		}
 
 		registrationService := &RegistrationService{
-			intervalDuration:    1 * time.Millisecond,
+			schedulePolicy: SchedulePolicy{
+				Interval:            time.Millisecond,
+				FailureInitialDelay: time.Millisecond,
+				FailureMaxDelay:     time.Millisecond,
+				RegisteredInterval:  time.Millisecond,
+				RebootPollInterval:  time.Millisecond,
+			},
+			checkTimeout:        time.Minute,
 			serverMetrics:       metricsRegistry,
 			registrationChecker: testRegistrationChecker,
 			log:                 observedLogger,
@@ -134,3 +192,262 @@ func thisLogEntryEqualTo(t testing.TB, this, other observer.LoggedEntry, msg str
 	assert.Equal(t, this.Message, other.Message, msg)
 
 }
+
+// blockingRegistrationChecker holds every check until it is released
+type blockingRegistrationChecker struct {
+	started chan struct{}
+	release chan struct{}
+	calls   atomic.Int32
+}
+
+func (rc *blockingRegistrationChecker) Check(ctx context.Context) (CheckResult, error) {
+	rc.calls.Add(1)
+	rc.started <- struct{}{}
+	<-rc.release
+	return CheckResult{StatusCodeMetric: metrics.StatusCodeMetric{Status: metrics.PlatformDirectlyRegistered}}, nil
+}
+
+func TestRegistrationServiceTriggerCheck(t *testing.T) {
+	checker := &blockingRegistrationChecker{started: make(chan struct{}, 10), release: make(chan struct{})}
+	registrationService := &RegistrationService{
+		schedulePolicy:      DefaultSchedulePolicy(),
+		checkTimeout:        time.Minute,
+		serverMetrics:       metrics.NewRegistrationServiceMetricsRegistry(zap.NewNop()),
+		registrationChecker: checker,
+		log:                 zap.NewNop(),
+	}
+
+	_, err := registrationService.TriggerCheck(context.Background())
+	assert.ErrorIs(t, err, ErrServiceNotRunning)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() { _ = registrationService.Run(ctx) }()
+	<-checker.started
+
+	// the triggered checks join the first check of the service
+	results := make(chan CheckResult, 2)
+	for range 2 {
+		go func() {
+			checkResult, err := registrationService.TriggerCheck(context.Background())
+			assert.NoError(t, err)
+			results <- checkResult
+		}()
+	}
+	time.Sleep(50 * time.Millisecond)
+	close(checker.release)
+	for range 2 {
+		assert.Equal(t, metrics.PlatformDirectlyRegistered, (<-results).Status)
+	}
+	assert.Equal(t, int32(1), checker.calls.Load())
+
+	// a check triggered once the previous one completed runs immediately
+	checkResult, err := registrationService.TriggerCheck(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, metrics.PlatformDirectlyRegistered, checkResult.Status)
+	assert.Equal(t, int32(2), checker.calls.Load())
+}
+
+func TestSchedulePolicyNextDelay(t *testing.T) {
+	policy := SchedulePolicy{
+		Interval:            time.Hour,
+		FailureInitialDelay: time.Minute,
+		FailureMaxDelay:     10 * time.Minute,
+		RegisteredInterval:  12 * time.Hour,
+		RebootPollInterval:  5 * time.Minute,
+	}
+	cases := []struct {
+		msg                 string
+		status              metrics.StatusCode
+		consecutiveFailures int
+		expectedDelay       time.Duration
+	}{
+		{msg: "registered platform confirmed at the slow interval", status: metrics.PlatformDirectlyRegistered, expectedDelay: 12 * time.Hour},
+		{msg: "pending reboot polled", status: metrics.PlatformRebootNeeded, expectedDelay: 5 * time.Minute},
+		{msg: "pending add package reboot polled", status: metrics.AddPackageRebootNeeded, expectedDelay: 5 * time.Minute},
+		{msg: "pending TCB recovery reboot polled", status: metrics.TcbRecoveryRegistered, expectedDelay: 5 * time.Minute},
+		{msg: "other status at the default interval", status: metrics.RetryNeeded, expectedDelay: time.Hour},
+		{msg: "first connection failure", status: metrics.IntelConnectFailed, consecutiveFailures: 1, expectedDelay: time.Minute},
+		{msg: "second request failure doubles", status: metrics.IntelRegServiceRequestFailed, consecutiveFailures: 2, expectedDelay: 2 * time.Minute},
+		{msg: "fourth unknown error", status: metrics.UnknownError, consecutiveFailures: 4, expectedDelay: 8 * time.Minute},
+		{msg: "backoff capped", status: metrics.IntelConnectFailed, consecutiveFailures: 5, expectedDelay: 10 * time.Minute},
+		{msg: "backoff capped without overflow", status: metrics.IntelConnectFailed, consecutiveFailures: 100, expectedDelay: 10 * time.Minute},
+	}
+
+	for _, c := range cases {
+		assert.Equal(t, c.expectedDelay, policy.nextDelay(c.status, c.consecutiveFailures), c.msg)
+	}
+}
+
+func TestSchedulePolicyValidate(t *testing.T) {
+	cases := []struct {
+		msg         string
+		modify      func(policy *SchedulePolicy)
+		expectError bool
+	}{
+		{msg: "default policy", modify: func(policy *SchedulePolicy) {}},
+		{msg: "zero interval", modify: func(policy *SchedulePolicy) { policy.Interval = 0 }, expectError: true},
+		{msg: "negative reboot poll interval", modify: func(policy *SchedulePolicy) { policy.RebootPollInterval = -time.Minute }, expectError: true},
+		{
+			msg: "initial delay above max delay",
+			modify: func(policy *SchedulePolicy) {
+				policy.FailureInitialDelay = 2 * time.Hour
+				policy.FailureMaxDelay = time.Hour
+			},
+			expectError: true,
+		},
+	}
+
+	for _, c := range cases {
+		policy := DefaultSchedulePolicy()
+		c.modify(&policy)
+		err := policy.Validate()
+		if c.expectError {
+			assert.Error(t, err, c.msg)
+		} else {
+			assert.NoError(t, err, c.msg)
+		}
+	}
+}
+
+func TestRegistrationServiceSchedulesNextCheck(t *testing.T) {
+	checker := &TestRegistrationChecker{metricSteps: []metrics.StatusCode{
+		metrics.IntelConnectFailed,
+		metrics.UnknownError,
+		metrics.PlatformDirectlyRegistered,
+		metrics.IntelConnectFailed,
+	}}
+	registrationService := &RegistrationService{
+		schedulePolicy:      DefaultSchedulePolicy(),
+		checkTimeout:        time.Minute,
+		serverMetrics:       metrics.NewRegistrationServiceMetricsRegistry(zap.NewNop()),
+		registrationChecker: checker,
+		log:                 zap.NewNop(),
+	}
+
+	// the failures back off, a success resets the backoff
+	expectedDelays := []time.Duration{
+		time.Minute,
+		2 * time.Minute,
+		DefaultSchedulePolicy().RegisteredInterval,
+		time.Minute,
+	}
+	for i, expectedDelay := range expectedDelays {
+		before := time.Now()
+		registrationService.CheckRegistrationStatus(context.Background())
+		nextCheckAt := registrationService.nextCheck()
+		msg := fmt.Sprintf("check %d", i)
+		assert.WithinRange(t, nextCheckAt, before.Add(expectedDelay), time.Now().Add(expectedDelay), msg)
+		assert.Equal(t, float64(nextCheckAt.Unix()), testutil.ToFloat64(metrics.NextCheckMetric), msg)
+	}
+}
+
+func TestRegistrationServiceReschedulesAfterTriggeredCheck(t *testing.T) {
+	checker := &TestRegistrationChecker{metricSteps: []metrics.StatusCode{
+		metrics.PlatformDirectlyRegistered,
+		metrics.IntelConnectFailed,
+	}}
+	policy := DefaultSchedulePolicy()
+	policy.FailureInitialDelay = 20 * time.Millisecond
+	registrationService := &RegistrationService{
+		schedulePolicy:      policy,
+		checkTimeout:        time.Minute,
+		serverMetrics:       metrics.NewRegistrationServiceMetricsRegistry(zap.NewNop()),
+		registrationChecker: checker,
+		log:                 zap.NewNop(),
+		rescheduled:         make(chan struct{}, 1),
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() { _ = registrationService.Run(ctx) }()
+	require.Eventually(t, func() bool { return registrationService.LastCheckResult() != nil }, time.Second, time.Millisecond)
+
+	// the triggered check fails, so the next check is due after the failure delay instead of the registered interval
+	checkResult, err := registrationService.TriggerCheck(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, metrics.IntelConnectFailed, checkResult.Status)
+	assert.Eventually(t, func() bool {
+		return registrationService.LastCheckResult().Status == metrics.PlatformDirectlyRegistered
+	}, 5*time.Second, time.Millisecond)
+}
+
+// recordingPublisher records the published check results
+type recordingPublisher struct {
+	published []CheckResult
+	err       error
+}
+
+func (p *recordingPublisher) Publish(ctx context.Context, checkResult CheckResult) error {
+	if _, ok := ctx.Deadline(); !ok {
+		return fmt.Errorf("publication without deadline")
+	}
+	p.published = append(p.published, checkResult)
+	return p.err
+}
+
+func TestRegistrationServicePublishesCheck(t *testing.T) {
+	cases := []struct {
+		msg             string
+		dryRun          bool
+		canceled        bool
+		publisherErr    error
+		wantedPublished int
+	}{
+		{msg: "completed check published", wantedPublished: 1},
+		{msg: "publication failure only logged", publisherErr: fmt.Errorf("forbidden"), wantedPublished: 1},
+		{msg: "dry-run check not published", dryRun: true},
+		{msg: "check interrupted by the shutdown not published", canceled: true},
+	}
+
+	for _, c := range cases {
+		publishers := []*recordingPublisher{{err: c.publisherErr}, {}}
+		registrationService := &RegistrationService{
+			checkTimeout:        time.Minute,
+			serverMetrics:       metrics.NewRegistrationServiceMetricsRegistry(zap.NewNop()),
+			registrationChecker: &TestRegistrationChecker{metricSteps: []metrics.StatusCode{metrics.PlatformDirectlyRegistered}},
+			log:                 zap.NewNop(),
+			dryRun:              c.dryRun,
+			publishers:          []CheckPublisher{publishers[0], publishers[1]},
+		}
+		ctx, cancel := context.WithCancel(context.Background())
+		if c.canceled {
+			cancel()
+		}
+
+		before := time.Now()
+		registrationService.CheckRegistrationStatus(ctx)
+		cancel()
+		for _, publisher := range publishers {
+			if assert.Len(t, publisher.published, c.wantedPublished, c.msg) && c.wantedPublished > 0 {
+				assert.Equal(t, metrics.PlatformDirectlyRegistered, publisher.published[0].Status, c.msg)
+				assert.WithinRange(t, publisher.published[0].CheckedAt, before, time.Now(), c.msg)
+			}
+		}
+	}
+}
+
+func TestRegistrationServiceSetsPreviousStatus(t *testing.T) {
+	publisher := &recordingPublisher{}
+	registrationService := &RegistrationService{
+		checkTimeout:  time.Minute,
+		serverMetrics: metrics.NewRegistrationServiceMetricsRegistry(zap.NewNop()),
+		registrationChecker: &TestRegistrationChecker{metricSteps: []metrics.StatusCode{
+			metrics.PlatformRebootNeeded, metrics.PlatformDirectlyRegistered, metrics.PlatformDirectlyRegistered,
+		}},
+		log:        zap.NewNop(),
+		publishers: []CheckPublisher{publisher},
+	}
+
+	for range 3 {
+		registrationService.CheckRegistrationStatus(context.Background())
+	}
+	require.Len(t, publisher.published, 3)
+	assert.Equal(t, metrics.Pending, publisher.published[0].PreviousStatus, "first check")
+	assert.Equal(t, metrics.PlatformRebootNeeded, publisher.published[1].PreviousStatus, "check after the reboot")
+	assert.Equal(t, metrics.PlatformDirectlyRegistered, publisher.published[2].PreviousStatus, "unchanged status")
+
+	// the status of the restored last check precedes the first check after a restart
+	registrationService.lastCheckResult = &CheckResult{StatusCodeMetric: metrics.StatusCodeMetric{Status: metrics.IntelConnectFailed}}
+	assert.Equal(t, metrics.IntelConnectFailed, registrationService.CheckRegistrationStatus(context.Background()).PreviousStatus)
+}
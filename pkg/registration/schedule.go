@@ -0,0 +1,76 @@
+package registration
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/opensovereigncloud/cc-intel-platform-registration/pkg/constants"
+	"github.com/opensovereigncloud/cc-intel-platform-registration/pkg/metrics"
+)
+
+// SchedulePolicy sets the delay before the next registration check according to the status of the last one
+type SchedulePolicy struct {
+	// Interval is the delay after the statuses without a dedicated interval
+	Interval time.Duration
+	// FailureInitialDelay is the delay after a first failure (10, 12 or 99); it doubles for every further
+	// consecutive failure
+	FailureInitialDelay time.Duration
+	// FailureMaxDelay caps the exponential delay after consecutive failures
+	FailureMaxDelay time.Duration
+	// RegisteredInterval is the delay after a check confirming the platform is registered (09)
+	RegisteredInterval time.Duration
+	// RebootPollInterval is the delay after a check finding the platform waiting for a reboot (05, 06 or 07)
+	RebootPollInterval time.Duration
+}
+
+// DefaultSchedulePolicy returns the schedule policy used when none is configured
+func DefaultSchedulePolicy() SchedulePolicy {
+	return SchedulePolicy{
+		Interval:            constants.DefaultRegistrationServiceIntervalInMinutes * time.Minute,
+		FailureInitialDelay: constants.DefaultScheduleFailureInitialDelay,
+		FailureMaxDelay:     constants.DefaultScheduleFailureMaxDelay,
+		RegisteredInterval:  constants.DefaultScheduleRegisteredInterval,
+		RebootPollInterval:  constants.DefaultScheduleRebootPollInterval,
+	}
+}
+
+// Validate checks that the schedule policy delays are consistent
+func (p SchedulePolicy) Validate() error {
+	if p.Interval <= 0 || p.FailureInitialDelay <= 0 || p.FailureMaxDelay <= 0 ||
+		p.RegisteredInterval <= 0 || p.RebootPollInterval <= 0 {
+		return fmt.Errorf("schedule intervals and delays must be positive")
+	}
+	if p.FailureInitialDelay > p.FailureMaxDelay {
+		return fmt.Errorf("failure initial delay %s exceeds failure max delay %s", p.FailureInitialDelay, p.FailureMaxDelay)
+	}
+	return nil
+}
+
+// isFailureStatus reports whether the status is a failure the checks back off from
+func isFailureStatus(status metrics.StatusCode) bool {
+	switch status {
+	case metrics.IntelConnectFailed, metrics.IntelRegServiceRequestFailed, metrics.UnknownError:
+		return true
+	default:
+		return false
+	}
+}
+
+// nextDelay returns the delay before the check following a check of the given status; consecutiveFailures
+// counts the failures in a row up to that check, 1 for a first failure
+func (p SchedulePolicy) nextDelay(status metrics.StatusCode, consecutiveFailures int) time.Duration {
+	switch {
+	case status == metrics.PlatformDirectlyRegistered:
+		return p.RegisteredInterval
+	case status.RebootNeeded():
+		return p.RebootPollInterval
+	case !isFailureStatus(status):
+		return p.Interval
+	}
+	if shift := consecutiveFailures - 1; shift < 32 {
+		if exponential := p.FailureInitialDelay << max(shift, 0); exponential > 0 && exponential < p.FailureMaxDelay {
+			return exponential
+		}
+	}
+	return p.FailureMaxDelay
+}
@@ -0,0 +1,168 @@
+package registrationresource
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/opensovereigncloud/cc-intel-platform-registration/pkg/metrics"
+	"github.com/opensovereigncloud/cc-intel-platform-registration/pkg/registration"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/util/retry"
+)
+
+// Kind of the cluster-scoped custom resource holding the registration status of a node
+const Kind = "SgxPlatformRegistration"
+
+// GroupVersionResource of the SgxPlatformRegistration custom resource, see charts/crds
+var GroupVersionResource = schema.GroupVersionResource{
+	Group:    "sgx.opensovereigncloud.io",
+	Version:  "v1alpha1",
+	Resource: "sgxplatformregistrations",
+}
+
+// FieldManager identifies the agent in the managed fields of the custom resource
+const FieldManager = "cc-intel-platform-registration"
+
+// MaxTransitions bounds the status transitions kept in the status, oldest first
+const MaxTransitions = 20
+
+// Transition is a change of the registration status between two checks
+type Transition struct {
+	Time metav1.Time `json:"time"`
+	From int         `json:"from"`
+	To   int         `json:"to"`
+}
+
+// Status is the status subresource of an SgxPlatformRegistration, owned by the agent running on its node
+type Status struct {
+	// StatusCode is the status code of the last check, e.g. 9
+	StatusCode int `json:"statusCode"`
+	// Status is the name of the status code of the last check, e.g. PlatformDirectlyRegistered
+	Status         string `json:"status"`
+	HttpStatusCode string `json:"httpStatusCode,omitempty"`
+	IntelError     string `json:"intelError,omitempty"`
+	// IntelRequestIDs are the request IDs of the Intel responses of the last check, e.g. to open an Intel support case
+	IntelRequestIDs []string `json:"intelRequestIds,omitempty"`
+	// PceID and Fmspc are kept from the last check decoding them from the PCK certificate
+	PceID string `json:"pceId,omitempty"`
+	Fmspc string `json:"fmspc,omitempty"`
+	// TcbStatus, Tcbm and AdvisoryIDs are kept from the last check evaluating the TCB status of the platform
+	TcbStatus   string   `json:"tcbStatus,omitempty"`
+	Tcbm        string   `json:"tcbm,omitempty"`
+	AdvisoryIDs []string `json:"advisoryIds,omitempty"`
+	// RebootRequired is set while the platform waits for a reboot (05, 06 or 07)
+	RebootRequired bool `json:"rebootRequired"`
+	// RegistrationCompletedAt is when the registration was completed, while the platform waits for a reboot
+	RegistrationCompletedAt *metav1.Time `json:"registrationCompletedAt,omitempty"`
+	LastCheckTime           metav1.Time  `json:"lastCheckTime"`
+	// Transitions holds the last status transitions, oldest first
+	Transitions []Transition `json:"transitions,omitempty"`
+}
+
+// nextStatus returns the status following the result of a check. A transition is recorded when the status code
+// differs from the one of the current status, or from the previous status of the check for a new resource.
+func nextStatus(current *Status, checkResult registration.CheckResult) Status {
+	next := Status{
+		StatusCode:      int(checkResult.Status),
+		Status:          checkResult.Status.Name(),
+		HttpStatusCode:  checkResult.HttpStatusCode,
+		IntelError:      checkResult.IntelError,
+		IntelRequestIDs: checkResult.IntelRequestIDs(),
+		RebootRequired:  checkResult.Status.RebootNeeded(),
+		LastCheckTime:   metav1.NewTime(checkResult.CheckedAt),
+	}
+	previousStatus := checkResult.PreviousStatus
+	if current != nil {
+		previousStatus = metrics.StatusCode(current.StatusCode)
+		next.PceID, next.Fmspc = current.PceID, current.Fmspc
+		next.TcbStatus, next.Tcbm, next.AdvisoryIDs = current.TcbStatus, current.Tcbm, current.AdvisoryIDs
+		next.Transitions = current.Transitions
+	}
+
+	// the platform details are taken from the SGX extension of the PCK certificate, not the FMSPC header of the
+	// PCS response, which a caching service may omit
+	if pckCertificates := checkResult.PckCertificates; pckCertificates != nil && pckCertificates.Extensions != nil {
+		extensions := pckCertificates.Extensions
+		if extensions.Fmspc != "" {
+			next.Fmspc = extensions.Fmspc
+		}
+		if extensions.PceID != "" {
+			next.PceID = extensions.PceID
+		}
+	}
+	if evaluation := checkResult.TcbEvaluation; evaluation != nil {
+		next.TcbStatus, next.Tcbm, next.AdvisoryIDs = string(evaluation.Status), evaluation.Tcbm, evaluation.AdvisoryIDs
+	}
+	if !checkResult.RegistrationCompletedAt.IsZero() {
+		completedAt := metav1.NewTime(checkResult.RegistrationCompletedAt)
+		next.RegistrationCompletedAt = &completedAt
+	}
+	if previousStatus != checkResult.Status {
+		next.Transitions = append(next.Transitions, Transition{Time: next.LastCheckTime, From: int(previousStatus), To: next.StatusCode})
+		if len(next.Transitions) > MaxTransitions {
+			next.Transitions = next.Transitions[len(next.Transitions)-MaxTransitions:]
+		}
+	}
+	return next
+}
+
+// Publisher publishes the registration status on the SgxPlatformRegistration of the node running the agent
+type Publisher struct {
+	client   dynamic.Interface
+	nodeName string
+}
+
+// NewPublisher creates a publisher updating the SgxPlatformRegistration named after the given node
+func NewPublisher(client dynamic.Interface, nodeName string) *Publisher {
+	return &Publisher{client: client, nodeName: nodeName}
+}
+
+// Publish updates the status of the SgxPlatformRegistration of the node with the result of a check, creating
+// the resource if it does not exist yet
+func (p *Publisher) Publish(ctx context.Context, checkResult registration.CheckResult) error {
+	resources := p.client.Resource(GroupVersionResource)
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		object, err := resources.Get(ctx, p.nodeName, metav1.GetOptions{})
+		if apierrors.IsNotFound(err) {
+			object, err = resources.Create(ctx, p.newObject(), metav1.CreateOptions{FieldManager: FieldManager})
+		}
+		if err != nil {
+			return fmt.Errorf("failed to get the %s %s: %w", Kind, p.nodeName, err)
+		}
+
+		var current *Status
+		if currentStatus, found, _ := unstructured.NestedMap(object.Object, "status"); found {
+			current = &Status{}
+			if err := runtime.DefaultUnstructuredConverter.FromUnstructured(currentStatus, current); err != nil {
+				return fmt.Errorf("failed to decode the status of the %s %s: %w", Kind, p.nodeName, err)
+			}
+		}
+		next := nextStatus(current, checkResult)
+		status, err := runtime.DefaultUnstructuredConverter.ToUnstructured(&next)
+		if err != nil {
+			return fmt.Errorf("failed to encode the status of the %s %s: %w", Kind, p.nodeName, err)
+		}
+		object.Object["status"] = status
+
+		_, err = resources.UpdateStatus(ctx, object, metav1.UpdateOptions{FieldManager: FieldManager})
+		if err != nil {
+			return fmt.Errorf("failed to update the status of the %s %s: %w", Kind, p.nodeName, err)
+		}
+		return nil
+	})
+}
+
+// newObject returns a new SgxPlatformRegistration of the node, without status
+func (p *Publisher) newObject() *unstructured.Unstructured {
+	return &unstructured.Unstructured{Object: map[string]any{
+		"apiVersion": GroupVersionResource.GroupVersion().String(),
+		"kind":       Kind,
+		"metadata":   map[string]any{"name": p.nodeName},
+		"spec":       map[string]any{"nodeName": p.nodeName},
+	}}
+}
@@ -0,0 +1,173 @@
+package registrationresource
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	intelservices "github.com/opensovereigncloud/cc-intel-platform-registration/pkg/intel_services"
+	"github.com/opensovereigncloud/cc-intel-platform-registration/pkg/metrics"
+	pckcerts "github.com/opensovereigncloud/cc-intel-platform-registration/pkg/pck_certs"
+	"github.com/opensovereigncloud/cc-intel-platform-registration/pkg/registration"
+	tcbinfo "github.com/opensovereigncloud/cc-intel-platform-registration/pkg/tcb_info"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+const testNodeName = "sgx-node-1"
+
+func newFakeDynamicClient() *dynamicfake.FakeDynamicClient {
+	return dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(),
+		map[schema.GroupVersionResource]string{GroupVersionResource: Kind + "List"})
+}
+
+// getStatus returns the spec and the status of the SgxPlatformRegistration of the test node
+func getStatus(t *testing.T, client *dynamicfake.FakeDynamicClient) (map[string]any, Status) {
+	object, err := client.Resource(GroupVersionResource).Get(context.Background(), testNodeName, metav1.GetOptions{})
+	require.NoError(t, err)
+	spec, _, err := unstructured.NestedMap(object.Object, "spec")
+	require.NoError(t, err)
+	statusObject, _, err := unstructured.NestedMap(object.Object, "status")
+	require.NoError(t, err)
+	var status Status
+	require.NoError(t, runtime.DefaultUnstructuredConverter.FromUnstructured(statusObject, &status))
+	return spec, status
+}
+
+func TestPublisherPublish(t *testing.T) {
+	// the times of the resource are decoded in the local time zone, with a one second precision
+	checkedAt := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC).Local()
+	client := newFakeDynamicClient()
+	publisher := NewPublisher(client, testNodeName)
+
+	// a failed first check creates the resource
+	require.NoError(t, publisher.Publish(context.Background(), registration.CheckResult{
+		StatusCodeMetric: metrics.StatusCodeMetric{Status: metrics.IntelRegServiceRequestFailed, HttpStatusCode: "400", IntelError: "InvalidRequestSyntax"},
+		IntelResponses:   []*intelservices.IntelResponse{{Endpoint: "platform_registration", HttpStatusCode: 400, RequestID: "f2e1c0de"}},
+		PreviousStatus:   metrics.Pending,
+		CheckedAt:        checkedAt,
+	}))
+	spec, status := getStatus(t, client)
+	assert.Equal(t, map[string]any{"nodeName": testNodeName}, spec)
+	assert.Equal(t, Status{
+		StatusCode:      12,
+		Status:          "IntelRegServiceRequestFailed",
+		HttpStatusCode:  "400",
+		IntelError:      "InvalidRequestSyntax",
+		IntelRequestIDs: []string{"f2e1c0de"},
+		LastCheckTime:   metav1.NewTime(checkedAt),
+		Transitions:     []Transition{{Time: metav1.NewTime(checkedAt), From: 0, To: 12}},
+	}, status)
+
+	// the registered platform publishes its PCE-ID, FMSPC and TCB status
+	require.NoError(t, publisher.Publish(context.Background(), registration.CheckResult{
+		StatusCodeMetric:        metrics.StatusCodeMetric{Status: metrics.PlatformRebootNeeded},
+		PckCertificates:         &pckcerts.PckCertificates{Fmspc: "00906ED50001", Extensions: &pckcerts.SgxExtensions{Fmspc: "00906ED50000", PceID: "0000"}},
+		TcbEvaluation:           &tcbinfo.TcbEvaluation{Status: tcbinfo.TcbStatusSWHardeningNeeded, Tcbm: "0e0e0303ffff01000000000000000000000d", AdvisoryIDs: []string{"INTEL-SA-00615"}},
+		RegistrationCompletedAt: checkedAt.Add(time.Hour),
+		PreviousStatus:          metrics.IntelRegServiceRequestFailed,
+		CheckedAt:               checkedAt.Add(time.Hour),
+	}))
+	_, status = getStatus(t, client)
+	completedAt := metav1.NewTime(checkedAt.Add(time.Hour))
+	assert.Equal(t, Status{
+		StatusCode:              5,
+		Status:                  "PlatformRebootNeeded",
+		PceID:                   "0000",
+		Fmspc:                   "00906ED50000",
+		TcbStatus:               "SWHardeningNeeded",
+		Tcbm:                    "0e0e0303ffff01000000000000000000000d",
+		AdvisoryIDs:             []string{"INTEL-SA-00615"},
+		RebootRequired:          true,
+		RegistrationCompletedAt: &completedAt,
+		LastCheckTime:           metav1.NewTime(checkedAt.Add(time.Hour)),
+		Transitions: []Transition{
+			{Time: metav1.NewTime(checkedAt), From: 0, To: 12},
+			{Time: metav1.NewTime(checkedAt.Add(time.Hour)), From: 12, To: 5},
+		},
+	}, status)
+
+	// the platform details are kept by the checks without them, and an unchanged status adds no transition
+	for i, pckCertificates := range []*pckcerts.PckCertificates{
+		nil,
+		{Fmspc: "00906ED50001", Extensions: &pckcerts.SgxExtensions{}},
+	} {
+		hours := time.Duration(i + 2)
+		require.NoError(t, publisher.Publish(context.Background(), registration.CheckResult{
+			StatusCodeMetric: metrics.StatusCodeMetric{Status: metrics.IntelConnectFailed},
+			PckCertificates:  pckCertificates,
+			// the resource prevails over the previous status of the check
+			PreviousStatus: metrics.Pending,
+			CheckedAt:      checkedAt.Add(hours * time.Hour),
+		}))
+	}
+	_, status = getStatus(t, client)
+	assert.Equal(t, 10, status.StatusCode)
+	assert.False(t, status.RebootRequired)
+	assert.Nil(t, status.RegistrationCompletedAt)
+	assert.Equal(t, "0000", status.PceID)
+	assert.Equal(t, "00906ED50000", status.Fmspc)
+	assert.Equal(t, "SWHardeningNeeded", status.TcbStatus)
+	assert.Equal(t, []Transition{
+		{Time: metav1.NewTime(checkedAt), From: 0, To: 12},
+		{Time: metav1.NewTime(checkedAt.Add(time.Hour)), From: 12, To: 5},
+		{Time: metav1.NewTime(checkedAt.Add(2 * time.Hour)), From: 5, To: 10},
+	}, status.Transitions)
+}
+
+func TestPublisherPublishRetriesOnConflict(t *testing.T) {
+	client := newFakeDynamicClient()
+	conflicts := 0
+	client.PrependReactor("update", GroupVersionResource.Resource, func(action k8stesting.Action) (bool, runtime.Object, error) {
+		if action.GetSubresource() == "status" && conflicts == 0 {
+			conflicts++
+			return true, nil, apierrors.NewConflict(GroupVersionResource.GroupResource(), testNodeName, nil)
+		}
+		return false, nil, nil
+	})
+
+	require.NoError(t, NewPublisher(client, testNodeName).Publish(context.Background(), registration.CheckResult{
+		StatusCodeMetric: metrics.StatusCodeMetric{Status: metrics.PlatformDirectlyRegistered},
+		CheckedAt:        time.Now(),
+	}))
+	assert.Equal(t, 1, conflicts)
+	_, status := getStatus(t, client)
+	assert.Equal(t, 9, status.StatusCode)
+}
+
+func TestPublisherPublishFails(t *testing.T) {
+	client := newFakeDynamicClient()
+	client.PrependReactor("create", GroupVersionResource.Resource, func(k8stesting.Action) (bool, runtime.Object, error) {
+		return true, nil, apierrors.NewForbidden(GroupVersionResource.GroupResource(), testNodeName, nil)
+	})
+
+	err := NewPublisher(client, testNodeName).Publish(context.Background(), registration.CheckResult{
+		StatusCodeMetric: metrics.StatusCodeMetric{Status: metrics.PlatformDirectlyRegistered},
+		CheckedAt:        time.Now(),
+	})
+	assert.ErrorContains(t, err, testNodeName)
+	assert.True(t, apierrors.IsForbidden(err))
+}
+
+func TestNextStatusBoundsTransitions(t *testing.T) {
+	current := &Status{StatusCode: int(metrics.IntelConnectFailed)}
+	for i := range MaxTransitions {
+		current.Transitions = append(current.Transitions, Transition{From: i % 2, To: 10})
+	}
+
+	next := nextStatus(current, registration.CheckResult{
+		StatusCodeMetric: metrics.StatusCodeMetric{Status: metrics.PlatformDirectlyRegistered},
+		CheckedAt:        time.Now(),
+	})
+	require.Len(t, next.Transitions, MaxTransitions)
+	assert.Equal(t, current.Transitions[1:], next.Transitions[:MaxTransitions-1])
+	assert.Equal(t, 10, next.Transitions[MaxTransitions-1].From)
+	assert.Equal(t, 9, next.Transitions[MaxTransitions-1].To)
+}
@@ -0,0 +1,161 @@
+package statestore
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/opensovereigncloud/cc-intel-platform-registration/pkg/metrics"
+)
+
+// StateFileName is the name of the state file in the state directory
+const StateFileName = "registration_state.json"
+
+// stateVersion is the version of the state file format
+const stateVersion = 1
+
+// Registration is the registration history of the platform the registration checker relies on
+type Registration struct {
+	// WasRegistered is set once the platform was found or made registered
+	WasRegistered bool `json:"wasRegistered"`
+	// ManifestFingerprint is the SHA-256 fingerprint of the last platform manifest registered
+	ManifestFingerprint string `json:"manifestFingerprint,omitempty"`
+	// TcbRecovery is set while the platform waits for the reboot following a TCB recovery registration
+	TcbRecovery bool `json:"tcbRecovery,omitempty"`
+	// CompletedAt is when the registration was completed, while the platform waits for a reboot
+	CompletedAt time.Time `json:"completedAt,omitempty"`
+}
+
+// CheckRecord is the outcome of a registration check
+type CheckRecord struct {
+	Time            time.Time          `json:"time"`
+	Status          metrics.StatusCode `json:"status"`
+	HttpStatusCode  string             `json:"httpStatusCode,omitempty"`
+	IntelError      string             `json:"intelError,omitempty"`
+	IntelRequestIDs []string           `json:"intelRequestIds,omitempty"`
+	// ManifestFingerprint is the fingerprint of the platform manifest read during the check, if any
+	ManifestFingerprint string `json:"manifestFingerprint,omitempty"`
+}
+
+// Transition is a change of the registration status between two checks
+type Transition struct {
+	Time time.Time          `json:"time"`
+	From metrics.StatusCode `json:"from"`
+	To   metrics.StatusCode `json:"to"`
+}
+
+// State is the registration state persisted across restarts
+type State struct {
+	Version      int          `json:"version"`
+	Registration Registration `json:"registration"`
+	// Checks holds the last checks, oldest first
+	Checks []CheckRecord `json:"checks"`
+	// Transitions holds the last status transitions, oldest first
+	Transitions []Transition `json:"transitions"`
+}
+
+// LastCheck returns the last recorded check, or nil if there is none
+func (s *State) LastCheck() *CheckRecord {
+	if len(s.Checks) == 0 {
+		return nil
+	}
+	return &s.Checks[len(s.Checks)-1]
+}
+
+// Record appends a check, and the status transition it causes if any, keeping at most maxRecords of each
+func (s *State) Record(check CheckRecord, maxRecords int) {
+	if last := s.LastCheck(); last == nil || last.Status != check.Status {
+		from := metrics.Pending
+		if last != nil {
+			from = last.Status
+		}
+		s.Transitions = append(s.Transitions, Transition{Time: check.Time, From: from, To: check.Status})
+	}
+	s.Checks = append(s.Checks, check)
+	s.Checks = keepLast(s.Checks, maxRecords)
+	s.Transitions = keepLast(s.Transitions, maxRecords)
+}
+
+func keepLast[T any](records []T, maxRecords int) []T {
+	if maxRecords <= 0 || len(records) <= maxRecords {
+		return records
+	}
+	return append([]T(nil), records[len(records)-maxRecords:]...)
+}
+
+// Store persists the registration state in a JSON file of the state directory
+type Store struct {
+	path       string
+	maxRecords int
+}
+
+// NewStore creates a store keeping at most maxRecords checks and transitions in the given directory
+func NewStore(dir string, maxRecords int) *Store {
+	return &Store{path: filepath.Join(dir, StateFileName), maxRecords: maxRecords}
+}
+
+// MaxRecords returns the number of checks and transitions kept by the store
+func (s *Store) MaxRecords() int {
+	return s.maxRecords
+}
+
+// Load reads the persisted state; an empty state is returned when nothing was persisted yet
+func (s *Store) Load() (*State, error) {
+	content, err := os.ReadFile(s.path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return &State{Version: stateVersion}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the state file: %w", err)
+	}
+
+	state := &State{}
+	if err := json.Unmarshal(content, state); err != nil {
+		return nil, fmt.Errorf("failed to decode the state file %s: %w", s.path, err)
+	}
+	if state.Version != stateVersion {
+		return nil, fmt.Errorf("unsupported state file version %d, expected %d", state.Version, stateVersion)
+	}
+	return state, nil
+}
+
+// Save persists the state atomically: it is written to a temporary file of the state directory,
+// synced, then renamed over the state file, so a crash never leaves a partially written state
+func (s *Store) Save(state *State) error {
+	state.Version = stateVersion
+	content, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode the state: %w", err)
+	}
+
+	dir := filepath.Dir(s.path)
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return fmt.Errorf("failed to create the state directory: %w", err)
+	}
+	tmpFile, err := os.CreateTemp(dir, StateFileName+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to create the temporary state file: %w", err)
+	}
+	// removes the temporary file when it could not be renamed over the state file
+	defer func() { _ = os.Remove(tmpFile.Name()) }()
+
+	if _, err := tmpFile.Write(content); err != nil {
+		_ = tmpFile.Close()
+		return fmt.Errorf("failed to write the temporary state file: %w", err)
+	}
+	if err := tmpFile.Sync(); err != nil {
+		_ = tmpFile.Close()
+		return fmt.Errorf("failed to sync the temporary state file: %w", err)
+	}
+	if err := tmpFile.Close(); err != nil {
+		return fmt.Errorf("failed to close the temporary state file: %w", err)
+	}
+	if err := os.Rename(tmpFile.Name(), s.path); err != nil {
+		return fmt.Errorf("failed to replace the state file: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,121 @@
+package statestore
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/opensovereigncloud/cc-intel-platform-registration/pkg/metrics"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStateRecord(t *testing.T) {
+	cases := []struct {
+		msg               string
+		statuses          []metrics.StatusCode
+		maxRecords        int
+		wantedChecks      []metrics.StatusCode
+		wantedTransitions []Transition
+	}{
+		{
+			msg:               "first check is a transition from pending",
+			statuses:          []metrics.StatusCode{metrics.PlatformRebootNeeded},
+			maxRecords:        10,
+			wantedChecks:      []metrics.StatusCode{metrics.PlatformRebootNeeded},
+			wantedTransitions: []Transition{{From: metrics.Pending, To: metrics.PlatformRebootNeeded}},
+		},
+		{
+			msg:          "unchanged status is not a transition",
+			statuses:     []metrics.StatusCode{metrics.PlatformRebootNeeded, metrics.PlatformRebootNeeded, metrics.PlatformDirectlyRegistered},
+			maxRecords:   10,
+			wantedChecks: []metrics.StatusCode{metrics.PlatformRebootNeeded, metrics.PlatformRebootNeeded, metrics.PlatformDirectlyRegistered},
+			wantedTransitions: []Transition{
+				{From: metrics.Pending, To: metrics.PlatformRebootNeeded},
+				{From: metrics.PlatformRebootNeeded, To: metrics.PlatformDirectlyRegistered},
+			},
+		},
+		{
+			msg:          "oldest records are dropped",
+			statuses:     []metrics.StatusCode{metrics.RetryNeeded, metrics.IntelConnectFailed, metrics.RetryNeeded, metrics.PlatformDirectlyRegistered},
+			maxRecords:   2,
+			wantedChecks: []metrics.StatusCode{metrics.RetryNeeded, metrics.PlatformDirectlyRegistered},
+			wantedTransitions: []Transition{
+				{From: metrics.IntelConnectFailed, To: metrics.RetryNeeded},
+				{From: metrics.RetryNeeded, To: metrics.PlatformDirectlyRegistered},
+			},
+		},
+	}
+
+	for _, c := range cases {
+		state := &State{}
+		assert.Nil(t, state.LastCheck(), c.msg)
+		for _, status := range c.statuses {
+			state.Record(CheckRecord{Status: status}, c.maxRecords)
+		}
+
+		var checks []metrics.StatusCode
+		for _, check := range state.Checks {
+			checks = append(checks, check.Status)
+		}
+		assert.Equal(t, c.wantedChecks, checks, c.msg)
+		assert.Equal(t, c.wantedTransitions, state.Transitions, c.msg)
+		assert.Equal(t, c.statuses[len(c.statuses)-1], state.LastCheck().Status, c.msg)
+	}
+}
+
+func TestStoreSaveLoad(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "state")
+	store := NewStore(dir, 10)
+
+	state, err := store.Load()
+	require.NoError(t, err)
+	assert.Nil(t, state.LastCheck())
+
+	completedAt := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	state.Registration = Registration{WasRegistered: true, ManifestFingerprint: "fingerprint", CompletedAt: completedAt}
+	state.Record(CheckRecord{
+		Time:                completedAt,
+		Status:              metrics.PlatformRebootNeeded,
+		IntelRequestIDs:     []string{"request-id"},
+		ManifestFingerprint: "fingerprint",
+	}, store.MaxRecords())
+	require.NoError(t, store.Save(state))
+	require.NoError(t, store.Save(state))
+
+	loadedState, err := store.Load()
+	require.NoError(t, err)
+	assert.Equal(t, state, loadedState)
+
+	// only the state file is left in the state directory
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	if assert.Len(t, entries, 1) {
+		assert.Equal(t, StateFileName, entries[0].Name())
+	}
+}
+
+func TestStoreLoadInvalidState(t *testing.T) {
+	cases := []struct {
+		msg     string
+		content string
+	}{
+		{
+			msg:     "corrupted state file",
+			content: `{"version": 1, "checks": [`,
+		},
+		{
+			msg:     "unsupported state file version",
+			content: `{"version": 2}`,
+		},
+	}
+
+	for _, c := range cases {
+		dir := t.TempDir()
+		require.NoError(t, os.WriteFile(filepath.Join(dir, StateFileName), []byte(c.content), 0o600), c.msg)
+
+		_, err := NewStore(dir, 10).Load()
+		assert.Error(t, err, c.msg)
+	}
+}
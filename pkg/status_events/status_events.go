@@ -0,0 +1,174 @@
+package statusevents
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/opensovereigncloud/cc-intel-platform-registration/pkg/constants"
+	"github.com/opensovereigncloud/cc-intel-platform-registration/pkg/metrics"
+	"github.com/opensovereigncloud/cc-intel-platform-registration/pkg/registration"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/scheme"
+	typedcorev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+	"k8s.io/client-go/tools/record"
+)
+
+// Component is the source component of the events
+const Component = "cc-intel-platform-registration"
+
+// correlatorOptions rate limit and aggregate the events of a flapping status: each object receives a burst of
+// 10 events, then one event every 5 minutes, and the similar events (same reason, other message) are aggregated
+// into a single event after 5 occurrences within 10 minutes
+var correlatorOptions = record.CorrelatorOptions{
+	BurstSize:            10,
+	QPS:                  1. / 300.,
+	MaxEvents:            5,
+	MaxIntervalInSeconds: 600,
+}
+
+// NodeReference returns the reference of a node in its events. Following the kubelet, the name of the node is
+// used as its UID, which is how `kubectl describe node` finds the events of the node.
+func NodeReference(nodeName string) *corev1.ObjectReference {
+	return &corev1.ObjectReference{APIVersion: "v1", Kind: "Node", Name: nodeName, UID: types.UID(nodeName)}
+}
+
+// PodReference returns the reference of a pod in its events
+func PodReference(namespace, name, uid string) *corev1.ObjectReference {
+	return &corev1.ObjectReference{APIVersion: "v1", Kind: "Pod", Namespace: namespace, Name: name, UID: types.UID(uid)}
+}
+
+// EventType returns the type of the events of a registration status: Normal when the platform is registered or
+// waits for a reboot, Warning otherwise
+func EventType(status metrics.StatusCode) string {
+	if status == metrics.PlatformDirectlyRegistered || status.RebootNeeded() {
+		return corev1.EventTypeNormal
+	}
+	return corev1.EventTypeWarning
+}
+
+// pendingSink counts the events queued on the broadcaster and not written to the sink yet, so the shutdown can
+// wait for them. The events skipped by the correlator (rate limited) or failing on every retry are never
+// written, the wait is bounded.
+type pendingSink struct {
+	record.EventSink
+
+	mutex   sync.Mutex
+	pending int
+	// idle is closed while no event is pending
+	idle chan struct{}
+}
+
+func newPendingSink(sink record.EventSink) *pendingSink {
+	idle := make(chan struct{})
+	close(idle)
+	return &pendingSink{EventSink: sink, idle: idle}
+}
+
+// queue counts an event queued on the broadcaster
+func (s *pendingSink) queue() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.pending == 0 {
+		s.idle = make(chan struct{})
+	}
+	s.pending++
+}
+
+// written counts an event written to the sink, a new event or the update of an aggregated one
+func (s *pendingSink) written(err error) {
+	if err != nil {
+		return
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.pending == 0 {
+		return
+	}
+	s.pending--
+	if s.pending == 0 {
+		close(s.idle)
+	}
+}
+
+// wait waits for the pending events to be written, until the timeout
+func (s *pendingSink) wait(timeout time.Duration) {
+	s.mutex.Lock()
+	idle := s.idle
+	s.mutex.Unlock()
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case <-idle:
+	case <-timer.C:
+	}
+}
+
+func (s *pendingSink) Create(event *corev1.Event) (*corev1.Event, error) {
+	event, err := s.EventSink.Create(event)
+	s.written(err)
+	return event, err
+}
+
+func (s *pendingSink) Update(event *corev1.Event) (*corev1.Event, error) {
+	event, err := s.EventSink.Update(event)
+	s.written(err)
+	return event, err
+}
+
+func (s *pendingSink) Patch(oldEvent *corev1.Event, data []byte) (*corev1.Event, error) {
+	event, err := s.EventSink.Patch(oldEvent, data)
+	s.written(err)
+	return event, err
+}
+
+// Publisher emits a Kubernetes event on the node running the agent, and on the pod of the agent, whenever the
+// registration status changes
+type Publisher struct {
+	broadcaster  record.EventBroadcaster
+	recorder     record.EventRecorder
+	sink         *pendingSink
+	flushTimeout time.Duration
+	objects      []*corev1.ObjectReference
+}
+
+// NewPublisher creates a publisher recording the events of the objects through the API server; the recording
+// runs in the background until Shutdown
+func NewPublisher(client kubernetes.Interface, nodeName string, objects ...*corev1.ObjectReference) *Publisher {
+	broadcaster := record.NewBroadcaster(record.WithCorrelatorOptions(correlatorOptions))
+	sink := newPendingSink(&typedcorev1.EventSinkImpl{Interface: client.CoreV1().Events("")})
+	broadcaster.StartRecordingToSink(sink)
+	recorder := broadcaster.NewRecorder(scheme.Scheme, corev1.EventSource{Component: Component, Host: nodeName})
+	return &Publisher{broadcaster: broadcaster, recorder: recorder, sink: sink,
+		flushTimeout: constants.EventsFlushTimeout, objects: objects}
+}
+
+// Publish emits the event of a registration status transition. The checks leaving the status unchanged are
+// not published. The events are sent in the background, so Publish does not report their failures.
+func (p *Publisher) Publish(_ context.Context, checkResult registration.CheckResult) error {
+	if checkResult.Status == checkResult.PreviousStatus {
+		return nil
+	}
+	message := fmt.Sprintf("Registration status changed from %02d %s to %02d %s",
+		int(checkResult.PreviousStatus), checkResult.PreviousStatus.Name(), int(checkResult.Status), checkResult.Status)
+	if checkResult.IntelError != "" {
+		message += fmt.Sprintf(" (Intel error %s)", checkResult.IntelError)
+	}
+	for _, object := range p.objects {
+		if p.sink != nil {
+			p.sink.queue()
+		}
+		p.recorder.Event(object, EventType(checkResult.Status), checkResult.Status.Name(), message)
+	}
+	return nil
+}
+
+// Shutdown waits for the queued events to be sent, at most the flush timeout, then stops recording the events;
+// the events not sent by then are dropped
+func (p *Publisher) Shutdown() {
+	p.sink.wait(p.flushTimeout)
+	p.broadcaster.Shutdown()
+}
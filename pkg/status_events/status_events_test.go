@@ -0,0 +1,153 @@
+package statusevents
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/opensovereigncloud/cc-intel-platform-registration/pkg/metrics"
+	"github.com/opensovereigncloud/cc-intel-platform-registration/pkg/registration"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+	"k8s.io/client-go/tools/record"
+)
+
+const testNodeName = "sgx-node-1"
+
+func TestEventType(t *testing.T) {
+	cases := []struct {
+		msg        string
+		status     metrics.StatusCode
+		wantedType string
+	}{
+		{msg: "registered", status: metrics.PlatformDirectlyRegistered, wantedType: corev1.EventTypeNormal},
+		{msg: "platform reboot needed", status: metrics.PlatformRebootNeeded, wantedType: corev1.EventTypeNormal},
+		{msg: "TCB recovery reboot needed", status: metrics.TcbRecoveryRegistered, wantedType: corev1.EventTypeNormal},
+		{msg: "SGX reset needed", status: metrics.SgxResetNeeded, wantedType: corev1.EventTypeWarning},
+		{msg: "Intel connection failed", status: metrics.IntelConnectFailed, wantedType: corev1.EventTypeWarning},
+	}
+
+	for _, c := range cases {
+		assert.Equal(t, c.wantedType, EventType(c.status), c.msg)
+	}
+}
+
+func TestPublisherPublish(t *testing.T) {
+	cases := []struct {
+		msg            string
+		checkResult    registration.CheckResult
+		wantedEvent    string
+		wantedNoEvents bool
+	}{
+		{
+			msg: "pending to reboot needed",
+			checkResult: registration.CheckResult{
+				StatusCodeMetric: metrics.StatusCodeMetric{Status: metrics.PlatformRebootNeeded},
+				PreviousStatus:   metrics.Pending,
+			},
+			wantedEvent: "Normal PlatformRebootNeeded Registration status changed from 00 Pending to " +
+				"05 PlatformRebootNeeded: platform registered successfully and a reboot is required",
+		},
+		{
+			msg: "registered to SGX reset needed",
+			checkResult: registration.CheckResult{
+				StatusCodeMetric: metrics.StatusCodeMetric{Status: metrics.SgxResetNeeded, HttpStatusCode: "400", IntelError: "InvalidRegistrationServer"},
+				PreviousStatus:   metrics.PlatformDirectlyRegistered,
+			},
+			wantedEvent: "Warning SgxResetNeeded Registration status changed from 09 PlatformDirectlyRegistered to " +
+				"03 SgxResetNeeded: impossible to determine the registration status; please reset the SGX (Intel error InvalidRegistrationServer)",
+		},
+		{
+			msg: "unchanged status",
+			checkResult: registration.CheckResult{
+				StatusCodeMetric: metrics.StatusCodeMetric{Status: metrics.PlatformDirectlyRegistered},
+				PreviousStatus:   metrics.PlatformDirectlyRegistered,
+			},
+			wantedNoEvents: true,
+		},
+	}
+
+	for _, c := range cases {
+		recorder := record.NewFakeRecorder(10)
+		publisher := &Publisher{
+			recorder: recorder,
+			objects:  []*corev1.ObjectReference{NodeReference(testNodeName), PodReference("sgx", "cc-ipr-x7k2p", "4c0f1c2e")},
+		}
+
+		require.NoError(t, publisher.Publish(context.Background(), c.checkResult), c.msg)
+		close(recorder.Events)
+		var events []string
+		for event := range recorder.Events {
+			events = append(events, event)
+		}
+		if c.wantedNoEvents {
+			assert.Empty(t, events, c.msg)
+		} else {
+			// one event on the node, one on the pod
+			assert.Equal(t, []string{c.wantedEvent, c.wantedEvent}, events, c.msg)
+		}
+	}
+}
+
+func TestNewPublisherRecordsEvents(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	publisher := NewPublisher(client, testNodeName, NodeReference(testNodeName))
+	defer publisher.Shutdown()
+
+	require.NoError(t, publisher.Publish(context.Background(), registration.CheckResult{
+		StatusCodeMetric: metrics.StatusCodeMetric{Status: metrics.PlatformDirectlyRegistered},
+		PreviousStatus:   metrics.PlatformRebootNeeded,
+	}))
+
+	// the node events are recorded in the default namespace, like the kubelet ones
+	var events *corev1.EventList
+	require.Eventually(t, func() bool {
+		var err error
+		events, err = client.CoreV1().Events(metav1.NamespaceDefault).List(context.Background(), metav1.ListOptions{})
+		return err == nil && len(events.Items) == 1
+	}, 5*time.Second, 10*time.Millisecond)
+	event := events.Items[0]
+	assert.Equal(t, "PlatformDirectlyRegistered", event.Reason)
+	assert.Equal(t, corev1.EventTypeNormal, event.Type)
+	assert.Equal(t, *NodeReference(testNodeName), event.InvolvedObject)
+	assert.Equal(t, corev1.EventSource{Component: Component, Host: testNodeName}, event.Source)
+}
+
+func TestPublisherShutdownSendsQueuedEvents(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	publisher := NewPublisher(client, testNodeName, NodeReference(testNodeName), PodReference("sgx", "cc-ipr-x7k2p", "4c0f1c2e"))
+
+	// a one-shot check shuts the publisher down right after publishing its transition
+	require.NoError(t, publisher.Publish(context.Background(), registration.CheckResult{
+		StatusCodeMetric: metrics.StatusCodeMetric{Status: metrics.PlatformDirectlyRegistered},
+		PreviousStatus:   metrics.Pending,
+	}))
+	publisher.Shutdown()
+
+	events, err := client.CoreV1().Events(metav1.NamespaceAll).List(context.Background(), metav1.ListOptions{})
+	require.NoError(t, err)
+	assert.Len(t, events.Items, 2)
+}
+
+func TestPublisherShutdownIsBounded(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	client.PrependReactor("create", "events", func(k8stesting.Action) (bool, runtime.Object, error) {
+		return true, nil, errors.New("API server unavailable")
+	})
+	publisher := NewPublisher(client, testNodeName, NodeReference(testNodeName))
+	publisher.flushTimeout = 50 * time.Millisecond
+
+	require.NoError(t, publisher.Publish(context.Background(), registration.CheckResult{
+		StatusCodeMetric: metrics.StatusCodeMetric{Status: metrics.PlatformDirectlyRegistered},
+		PreviousStatus:   metrics.Pending,
+	}))
+	start := time.Now()
+	publisher.Shutdown()
+	assert.Less(t, time.Since(start), 5*time.Second)
+}
@@ -0,0 +1,54 @@
+package tcbinfo
+
+import (
+	"time"
+
+	pckcerts "github.com/opensovereigncloud/cc-intel-platform-registration/pkg/pck_certs"
+)
+
+// TcbEvaluation is the TCB status of the platform according to the TCB Info of its FMSPC
+type TcbEvaluation struct {
+	Status      TcbStatus
+	AdvisoryIDs []string
+	// Tcbm is the TCBm of the PCK certificate matching the TCB level, empty when the status is Unsupported
+	Tcbm                    string
+	TcbDate                 time.Time
+	TcbEvaluationDataNumber int
+	NextUpdate              time.Time
+}
+
+// isHigherOrEqual reports whether every SGX TCB component and the PCE SVN of the TCB are higher or equal
+// to the ones of the TCB level
+func isHigherOrEqual(tcb, level pckcerts.Tcb) bool {
+	for i := range tcb.SgxTcbComponents {
+		if tcb.SgxTcbComponents[i] < level.SgxTcbComponents[i] {
+			return false
+		}
+	}
+	return tcb.PceSvn >= level.PceSvn
+}
+
+// Evaluate returns the status of the highest TCB level reached by an available PCK certificate of the platform.
+// The PCS issues a PCK certificate for every TCB level the registered platform reaches, so the best available
+// certificate reflects the TCB of the platform. The TCB levels of the TCB Info are sorted from the highest to
+// the lowest, hence the first level reached is the one matching the platform.
+func (t *TcbInfo) Evaluate(pckCertificates *pckcerts.PckCertificates) *TcbEvaluation {
+	evaluation := &TcbEvaluation{
+		Status:                  TcbStatusUnsupported,
+		TcbEvaluationDataNumber: t.TcbEvaluationDataNumber,
+		NextUpdate:              t.NextUpdate,
+	}
+	for _, level := range t.TcbLevels {
+		for _, pckCertificate := range pckCertificates.Certificates {
+			if pckCertificate.Certificate == nil || !isHigherOrEqual(pckCertificate.Tcb, level.Tcb) {
+				continue
+			}
+			evaluation.Status = level.TcbStatus
+			evaluation.AdvisoryIDs = level.AdvisoryIDs
+			evaluation.Tcbm = pckCertificate.Tcbm
+			evaluation.TcbDate = level.TcbDate
+			return evaluation
+		}
+	}
+	return evaluation
+}
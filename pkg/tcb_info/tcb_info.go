@@ -0,0 +1,154 @@
+package tcbinfo
+
+import (
+	"crypto/ecdsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strings"
+	"time"
+
+	pckcerts "github.com/opensovereigncloud/cc-intel-platform-registration/pkg/pck_certs"
+)
+
+// PCS response headers carrying the TCB Info issuer chain, for the PCS API v4 and v3 respectively
+const (
+	TcbInfoIssuerChainHeader    = "TCB-Info-Issuer-Chain"
+	SgxTcbInfoIssuerChainHeader = "SGX-TCB-Info-Issuer-Chain"
+)
+
+// ErrInvalid is wrapped by the errors reporting a TCB Info whose signature cannot be verified
+var ErrInvalid = errors.New("TCB Info is invalid")
+
+// ErrOutdated is wrapped by the errors reporting a TCB Info past its nextUpdate, e.g. served by a stale cache
+var ErrOutdated = errors.New("TCB Info is outdated")
+
+// signatureSize is the size of a raw (r || s) ECDSA P-256 signature
+const signatureSize = 64
+
+// TcbStatus is the status of a TCB level
+type TcbStatus string
+
+const (
+	TcbStatusUpToDate                          TcbStatus = "UpToDate"
+	TcbStatusSWHardeningNeeded                 TcbStatus = "SWHardeningNeeded"
+	TcbStatusConfigurationNeeded               TcbStatus = "ConfigurationNeeded"
+	TcbStatusConfigurationAndSWHardeningNeeded TcbStatus = "ConfigurationAndSWHardeningNeeded"
+	TcbStatusOutOfDate                         TcbStatus = "OutOfDate"
+	TcbStatusOutOfDateConfigurationNeeded      TcbStatus = "OutOfDateConfigurationNeeded"
+	TcbStatusRevoked                           TcbStatus = "Revoked"
+	// TcbStatusUnsupported is reported when the platform TCB is lower than every TCB level of the TCB Info
+	TcbStatusUnsupported TcbStatus = "Unsupported"
+)
+
+// TcbLevel is a TCB level of the TCB Info
+type TcbLevel struct {
+	Tcb         pckcerts.Tcb `json:"tcb"`
+	TcbDate     time.Time    `json:"tcbDate"`
+	TcbStatus   TcbStatus    `json:"tcbStatus"`
+	AdvisoryIDs []string     `json:"advisoryIDs"`
+}
+
+// TcbInfo is the SGX TCB Info of an FMSPC, as signed by the Intel TCB Signing key
+type TcbInfo struct {
+	ID                      string     `json:"id"`
+	Version                 int        `json:"version"`
+	IssueDate               time.Time  `json:"issueDate"`
+	NextUpdate              time.Time  `json:"nextUpdate"`
+	Fmspc                   string     `json:"fmspc"`
+	PceID                   string     `json:"pceId"`
+	TcbType                 int        `json:"tcbType"`
+	TcbEvaluationDataNumber int        `json:"tcbEvaluationDataNumber"`
+	TcbLevels               []TcbLevel `json:"tcbLevels"`
+}
+
+// SignedTcbInfo is the decoded response of the PCS tcb endpoint
+type SignedTcbInfo struct {
+	TcbInfo TcbInfo
+	// RawTcbInfo is the tcbInfo body as signed by Intel
+	RawTcbInfo []byte
+	// Signature is the raw (r || s) ECDSA P-256 signature of RawTcbInfo
+	Signature []byte
+	// IssuerChain holds the TCB Signing and root certificates
+	IssuerChain []*x509.Certificate
+}
+
+type tcbInfoResponse struct {
+	TcbInfo   json.RawMessage `json:"tcbInfo"`
+	Signature string          `json:"signature"`
+}
+
+// Parse decodes the body and the headers of a successful PCS tcb response
+func Parse(body []byte, header http.Header) (*SignedTcbInfo, error) {
+	var response tcbInfoResponse
+	if err := json.Unmarshal(body, &response); err != nil {
+		return nil, fmt.Errorf("failed to decode TCB Info: %w", err)
+	}
+	if len(response.TcbInfo) == 0 {
+		return nil, fmt.Errorf("TCB Info response has no tcbInfo")
+	}
+
+	signedTcbInfo := &SignedTcbInfo{RawTcbInfo: response.TcbInfo}
+	if err := json.Unmarshal(response.TcbInfo, &signedTcbInfo.TcbInfo); err != nil {
+		return nil, fmt.Errorf("failed to decode TCB Info: %w", err)
+	}
+	if len(signedTcbInfo.TcbInfo.TcbLevels) == 0 {
+		return nil, fmt.Errorf("TCB Info has no TCB level")
+	}
+	signedTcbInfo.TcbInfo.Fmspc = strings.ToLower(signedTcbInfo.TcbInfo.Fmspc)
+
+	signature, err := hex.DecodeString(response.Signature)
+	if err != nil {
+		return nil, fmt.Errorf("invalid TCB Info signature: %w", err)
+	}
+	signedTcbInfo.Signature = signature
+
+	issuerChainHeader := header.Get(TcbInfoIssuerChainHeader)
+	if issuerChainHeader == "" {
+		issuerChainHeader = header.Get(SgxTcbInfoIssuerChainHeader)
+	}
+	issuerChain, err := pckcerts.ParseIssuerChainHeader(issuerChainHeader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode %s header: %w", TcbInfoIssuerChainHeader, err)
+	}
+	signedTcbInfo.IssuerChain = issuerChain
+	return signedTcbInfo, nil
+}
+
+// Verify checks that the TCB Signing certificate chains up to the trusted root CA, that it signed the TCB Info
+// and that the TCB Info is issued for the expected FMSPC. The returned error wraps ErrInvalid when the
+// verification fails, or ErrOutdated when the TCB Info is past its nextUpdate, as its TCB statuses may no
+// longer be current.
+func (s *SignedTcbInfo) Verify(verifier *pckcerts.Verifier, fmspc string) error {
+	signingCertificate, err := verifier.VerifyIssuerChain(s.IssuerChain)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalid, err)
+	}
+
+	publicKey, ok := signingCertificate.PublicKey.(*ecdsa.PublicKey)
+	if !ok {
+		return fmt.Errorf("%w: TCB Signing certificate has no ECDSA public key", ErrInvalid)
+	}
+	if len(s.Signature) != signatureSize {
+		return fmt.Errorf("%w: expected a %d bytes signature, got %d", ErrInvalid, signatureSize, len(s.Signature))
+	}
+	digest := sha256.Sum256(s.RawTcbInfo)
+	r := new(big.Int).SetBytes(s.Signature[:signatureSize/2])
+	sv := new(big.Int).SetBytes(s.Signature[signatureSize/2:])
+	if !ecdsa.Verify(publicKey, digest[:], r, sv) {
+		return fmt.Errorf("%w: signature verification failed", ErrInvalid)
+	}
+
+	if !strings.EqualFold(s.TcbInfo.Fmspc, fmspc) {
+		return fmt.Errorf("%w: issued for FMSPC %s instead of %s", ErrInvalid, s.TcbInfo.Fmspc, fmspc)
+	}
+	if nextUpdate := s.TcbInfo.NextUpdate; !time.Now().Before(nextUpdate) {
+		return fmt.Errorf("%w: next update was due at %s", ErrOutdated, nextUpdate.Format(time.RFC3339))
+	}
+	return nil
+}
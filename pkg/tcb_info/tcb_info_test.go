@@ -0,0 +1,277 @@
+package tcbinfo
+
+import (
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	testcerts "github.com/opensovereigncloud/cc-intel-platform-registration/internal/pkg/test_certs"
+	pckcerts "github.com/opensovereigncloud/cc-intel-platform-registration/pkg/pck_certs"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testTcbInfo is a current TCB Info, due for an update in a month
+var testTcbInfo = newTestTcbInfo(time.Now().Add(30 * 24 * time.Hour))
+
+// newTestTcbInfo returns the tcbInfo body of a PCS response with the given nextUpdate
+func newTestTcbInfo(nextUpdate time.Time) string {
+	return `{"id":"SGX","version":3,"issueDate":"2026-10-01T00:00:00Z","nextUpdate":"` + nextUpdate.UTC().Format(time.RFC3339) + `",` +
+		`"fmspc":"00906ED50000","pceId":"0000","tcbType":0,"tcbEvaluationDataNumber":17,"tcbLevels":[` +
+		`{"tcb":{"sgxtcbcomponents":[{"svn":4},{"svn":4},{"svn":2},{"svn":2},{"svn":255},{"svn":1},{"svn":0},{"svn":0},{"svn":0},{"svn":0},{"svn":0},{"svn":0},{"svn":0},{"svn":0},{"svn":0},{"svn":0}],"pcesvn":13},"tcbDate":"2026-08-12T00:00:00Z","tcbStatus":"UpToDate"},` +
+		`{"tcb":{"sgxtcbcomponents":[{"svn":3},{"svn":3},{"svn":2},{"svn":2},{"svn":255},{"svn":1},{"svn":0},{"svn":0},{"svn":0},{"svn":0},{"svn":0},{"svn":0},{"svn":0},{"svn":0},{"svn":0},{"svn":0}],"pcesvn":13},"tcbDate":"2026-02-14T00:00:00Z","tcbStatus":"SWHardeningNeeded","advisoryIDs":["INTEL-SA-00615"]},` +
+		`{"tcb":{"sgxtcbcomponents":[{"svn":2},{"svn":2},{"svn":2},{"svn":2},{"svn":255},{"svn":1},{"svn":0},{"svn":0},{"svn":0},{"svn":0},{"svn":0},{"svn":0},{"svn":0},{"svn":0},{"svn":0},{"svn":0}],"pcesvn":11},"tcbDate":"2025-11-08T00:00:00Z","tcbStatus":"OutOfDate","advisoryIDs":["INTEL-SA-00615","INTEL-SA-00657"]}]}`
+}
+
+type testSigner struct {
+	root        *x509.Certificate
+	signingKey  *ecdsa.PrivateKey
+	issuerChain string
+}
+
+// newTestSigner returns a root CA and a TCB Signing certificate issued by it, as sent in the issuer chain header
+func newTestSigner(t testing.TB) testSigner {
+	t.Helper()
+	notAfter := time.Now().Add(24 * time.Hour)
+	root := testcerts.New(t, "Test SGX Root CA", nil, true, notAfter)
+	signing := testcerts.New(t, "Test SGX TCB Signing", &root, false, notAfter)
+	return testSigner{root: root.Certificate, signingKey: signing.Key, issuerChain: url.PathEscape(testcerts.EncodePEM(signing, root))}
+}
+
+// body returns the PCS tcb response body, signing the TCB Info
+func (s testSigner) body(t testing.TB, tcbInfo string) []byte {
+	t.Helper()
+	digest := sha256.Sum256([]byte(tcbInfo))
+	r, sv, err := ecdsa.Sign(rand.Reader, s.signingKey, digest[:])
+	require.NoError(t, err)
+	signature := make([]byte, signatureSize)
+	r.FillBytes(signature[:signatureSize/2])
+	sv.FillBytes(signature[signatureSize/2:])
+	body, err := json.Marshal(map[string]any{"tcbInfo": json.RawMessage(tcbInfo), "signature": hex.EncodeToString(signature)})
+	require.NoError(t, err)
+	return body
+}
+
+func newTestHeader(key, value string) http.Header {
+	header := http.Header{}
+	header.Set(key, value)
+	return header
+}
+
+func TestParse(t *testing.T) {
+	signer := newTestSigner(t)
+	body := signer.body(t, testTcbInfo)
+
+	cases := []struct {
+		msg         string
+		body        []byte
+		header      http.Header
+		expectError bool
+	}{
+		{
+			msg:    "v4 response is decoded",
+			body:   body,
+			header: newTestHeader(TcbInfoIssuerChainHeader, signer.issuerChain),
+		},
+		{
+			msg:    "v3 response is decoded",
+			body:   body,
+			header: newTestHeader(SgxTcbInfoIssuerChainHeader, signer.issuerChain),
+		},
+		{
+			msg:         "malformed body is rejected",
+			body:        []byte(`{"error":"oops"}`),
+			header:      newTestHeader(TcbInfoIssuerChainHeader, signer.issuerChain),
+			expectError: true,
+		},
+		{
+			msg:         "TCB Info without TCB level is rejected",
+			body:        signer.body(t, `{"fmspc":"00906ED50000","tcbLevels":[]}`),
+			header:      newTestHeader(TcbInfoIssuerChainHeader, signer.issuerChain),
+			expectError: true,
+		},
+		{
+			msg:         "malformed signature is rejected",
+			body:        []byte(`{"tcbInfo":` + testTcbInfo + `,"signature":"not hex"}`),
+			header:      newTestHeader(TcbInfoIssuerChainHeader, signer.issuerChain),
+			expectError: true,
+		},
+		{
+			msg:         "malformed issuer chain is rejected",
+			body:        body,
+			header:      newTestHeader(TcbInfoIssuerChainHeader, "not a certificate"),
+			expectError: true,
+		},
+	}
+
+	for _, c := range cases {
+		signedTcbInfo, err := Parse(c.body, c.header)
+		if c.expectError {
+			assert.Error(t, err, c.msg)
+			continue
+		}
+		require.NoError(t, err, c.msg)
+		assert.Equal(t, testTcbInfo, string(signedTcbInfo.RawTcbInfo), c.msg)
+		assert.Len(t, signedTcbInfo.IssuerChain, 2, c.msg)
+		assert.Equal(t, "00906ed50000", signedTcbInfo.TcbInfo.Fmspc, c.msg)
+		assert.Equal(t, 17, signedTcbInfo.TcbInfo.TcbEvaluationDataNumber, c.msg)
+		if assert.Len(t, signedTcbInfo.TcbInfo.TcbLevels, 3, c.msg) {
+			assert.Equal(t, TcbStatusSWHardeningNeeded, signedTcbInfo.TcbInfo.TcbLevels[1].TcbStatus, c.msg)
+			assert.Equal(t, []string{"INTEL-SA-00615"}, signedTcbInfo.TcbInfo.TcbLevels[1].AdvisoryIDs, c.msg)
+			assert.Equal(t, uint16(11), signedTcbInfo.TcbInfo.TcbLevels[2].Tcb.PceSvn, c.msg)
+		}
+	}
+}
+
+func TestVerify(t *testing.T) {
+	signer := newTestSigner(t)
+	otherSigner := newTestSigner(t)
+	header := newTestHeader(TcbInfoIssuerChainHeader, signer.issuerChain)
+
+	tampered, err := Parse(signer.body(t, testTcbInfo), header)
+	require.NoError(t, err)
+	tampered.RawTcbInfo = append([]byte(nil), tampered.RawTcbInfo...)
+	tampered.RawTcbInfo[len(tampered.RawTcbInfo)-2] = ' '
+
+	cases := []struct {
+		msg        string
+		body       []byte
+		signedInfo *SignedTcbInfo
+		rootCA     *x509.Certificate
+		fmspc      string
+		wantedErr  error
+	}{
+		{
+			msg:    "TCB Info signed by the trusted root CA is accepted",
+			body:   signer.body(t, testTcbInfo),
+			rootCA: signer.root,
+			fmspc:  "00906ed50000",
+		},
+		{
+			msg:       "TCB Info of another root CA is rejected",
+			body:      signer.body(t, testTcbInfo),
+			rootCA:    otherSigner.root,
+			fmspc:     "00906ed50000",
+			wantedErr: ErrInvalid,
+		},
+		{
+			msg:       "TCB Info signed by another key is rejected",
+			body:      otherSigner.body(t, testTcbInfo),
+			rootCA:    signer.root,
+			fmspc:     "00906ed50000",
+			wantedErr: ErrInvalid,
+		},
+		{
+			msg:        "tampered TCB Info is rejected",
+			signedInfo: tampered,
+			rootCA:     signer.root,
+			fmspc:      "00906ed50000",
+			wantedErr:  ErrInvalid,
+		},
+		{
+			msg:       "TCB Info of another FMSPC is rejected",
+			body:      signer.body(t, testTcbInfo),
+			rootCA:    signer.root,
+			fmspc:     "00606a000000",
+			wantedErr: ErrInvalid,
+		},
+		{
+			msg:       "outdated TCB Info is rejected",
+			body:      signer.body(t, newTestTcbInfo(time.Now().Add(-time.Hour))),
+			rootCA:    signer.root,
+			fmspc:     "00906ed50000",
+			wantedErr: ErrOutdated,
+		},
+	}
+
+	for _, c := range cases {
+		signedTcbInfo := c.signedInfo
+		if signedTcbInfo == nil {
+			signedTcbInfo, err = Parse(c.body, header)
+			require.NoError(t, err, c.msg)
+		}
+		err := signedTcbInfo.Verify(pckcerts.NewVerifier(c.rootCA), c.fmspc)
+		if c.wantedErr != nil {
+			assert.ErrorIs(t, err, c.wantedErr, c.msg)
+		} else {
+			assert.NoError(t, err, c.msg)
+		}
+	}
+}
+
+func newTestPckCertificate(components [pckcerts.SgxTcbComponentsCount]uint8, pceSvn uint16, tcbm string, available bool) pckcerts.PckCertificate {
+	pckCertificate := pckcerts.PckCertificate{Tcb: pckcerts.Tcb{SgxTcbComponents: components, PceSvn: pceSvn}, Tcbm: tcbm}
+	if available {
+		pckCertificate.Certificate = &x509.Certificate{}
+	}
+	return pckCertificate
+}
+
+func TestEvaluate(t *testing.T) {
+	var tcbInfo TcbInfo
+	require.NoError(t, json.Unmarshal([]byte(testTcbInfo), &tcbInfo))
+
+	upToDate := [pckcerts.SgxTcbComponentsCount]uint8{4, 4, 2, 2, 255, 1}
+	swHardeningNeeded := [pckcerts.SgxTcbComponentsCount]uint8{3, 3, 2, 2, 255, 1}
+	outOfDate := [pckcerts.SgxTcbComponentsCount]uint8{2, 2, 2, 2, 255, 1}
+	tooLow := [pckcerts.SgxTcbComponentsCount]uint8{1, 1, 1, 1, 1, 1}
+
+	cases := []struct {
+		msg               string
+		pckCertificates   []pckcerts.PckCertificate
+		wantedStatus      TcbStatus
+		wantedTcbm        string
+		wantedAdvisoryIDs []string
+	}{
+		{
+			msg: "best available PCK certificate reaches the highest TCB level",
+			pckCertificates: []pckcerts.PckCertificate{
+				newTestPckCertificate(upToDate, 13, "up-to-date", true),
+				newTestPckCertificate(outOfDate, 11, "out-of-date", true),
+			},
+			wantedStatus: TcbStatusUpToDate,
+			wantedTcbm:   "up-to-date",
+		},
+		{
+			msg: "unavailable PCK certificates are ignored",
+			pckCertificates: []pckcerts.PckCertificate{
+				newTestPckCertificate(upToDate, 13, "up-to-date", false),
+				newTestPckCertificate(swHardeningNeeded, 13, "sw-hardening", true),
+			},
+			wantedStatus:      TcbStatusSWHardeningNeeded,
+			wantedTcbm:        "sw-hardening",
+			wantedAdvisoryIDs: []string{"INTEL-SA-00615"},
+		},
+		{
+			msg: "lower PCE SVN does not reach the TCB level",
+			pckCertificates: []pckcerts.PckCertificate{
+				newTestPckCertificate(upToDate, 12, "old-pce", true),
+			},
+			wantedStatus:      TcbStatusOutOfDate,
+			wantedTcbm:        "old-pce",
+			wantedAdvisoryIDs: []string{"INTEL-SA-00615", "INTEL-SA-00657"},
+		},
+		{
+			msg: "TCB lower than every TCB level is unsupported",
+			pckCertificates: []pckcerts.PckCertificate{
+				newTestPckCertificate(tooLow, 13, "too-low", true),
+			},
+			wantedStatus: TcbStatusUnsupported,
+		},
+	}
+
+	for _, c := range cases {
+		evaluation := tcbInfo.Evaluate(&pckcerts.PckCertificates{Certificates: c.pckCertificates})
+		assert.Equal(t, c.wantedStatus, evaluation.Status, c.msg)
+		assert.Equal(t, c.wantedTcbm, evaluation.Tcbm, c.msg)
+		assert.Equal(t, c.wantedAdvisoryIDs, evaluation.AdvisoryIDs, c.msg)
+		assert.Equal(t, 17, evaluation.TcbEvaluationDataNumber, c.msg)
+	}
+}
@@ -173,6 +173,21 @@ MpResult MPManagement::getPlatformManifest(uint8_t *buffer, uint16_t &buffer_siz
     return getRequestData(buffer, buffer_size, MP_REQ_REGISTRATION);
 }
 
+MpResult MPManagement::getAddPackageRequest(uint8_t *buffer, uint16_t &buffer_size)
+{
+    return getRequestData(buffer, buffer_size, MP_REQ_ADD_PACKAGE);
+}
+
+MpResult MPManagement::getRequestType(MpRequestType &type)
+{
+    return m_mpuefi->getRequestType(type);
+}
+
+MpResult MPManagement::setServerResponse(const uint8_t *buffer, uint16_t buffer_size)
+{
+    return m_mpuefi->setServerResponse(buffer, buffer_size);
+}
+
 MPManagement::~MPManagement()
 {
     if (NULL != m_mpuefi)
@@ -35,6 +35,7 @@
  * communicate with the BIOS UEFI variables.
  */
 #include <string.h>
+#include <new>
 #include "include/FSUefi.h"
 #include "include/MPUefi.h"
 #include "include/UefiVar.h"
@@ -248,6 +249,53 @@ MpResult MPUefi::setRegistrationStatus(const MpRegistrationStatus &status)
     return res;
 }
 
+MpResult MPUefi::setServerResponse(const uint8_t *response, const uint16_t &responseSize)
+{
+    MpResult res = MP_SUCCESS;
+    SgxUefiVar *responseUefi = NULL;
+    size_t varDataSize = 0;
+
+    do
+    {
+        if (NULL == response || 0 == responseSize || MAX_RESPONSE_SIZE < responseSize)
+        {
+            res = MP_INVALID_PARAMETER;
+            break;
+        }
+
+        varDataSize = sizeof(responseUefi->version) + sizeof(responseUefi->size) + responseSize;
+        responseUefi = (SgxUefiVar *)new (std::nothrow) uint8_t[varDataSize];
+        if (NULL == responseUefi)
+        {
+            res = MP_MEM_ERROR;
+            break;
+        }
+
+        responseUefi->version = MP_BIOS_UEFI_VARIABLE_VERSION_1;
+        responseUefi->size = responseSize;
+        memcpy(&(responseUefi->header), response, responseSize);
+
+        // write server response to uefi, creating the variable if needed
+        int numOfBytes = m_uefi->writeUEFIVar(UEFI_VAR_SERVER_RESPONSE, (const uint8_t *)responseUefi, varDataSize, true);
+        if (numOfBytes != (int)varDataSize)
+        {
+            if (numOfBytes == -1)
+            {
+                res = MP_INSUFFICIENT_PRIVILEGES;
+                break;
+            }
+            res = MP_UEFI_INTERNAL_ERROR;
+            break;
+        }
+    } while (0);
+
+    if (responseUefi)
+    {
+        delete[] (uint8_t *)responseUefi;
+    }
+    return res;
+}
+
 MPUefi::~MPUefi()
 {
     if (NULL != m_uefi)
@@ -74,6 +74,33 @@ MpResult mp_management_set_registration_status_as_complete()
     return g_mpManagement->setRegistrationStatusAsComplete();
 }
 
+MpResult mp_management_get_request_type(MpRequestType *type)
+{
+    if (!type)
+    {
+        return MP_INVALID_PARAMETER;
+    }
+    return g_mpManagement->getRequestType(*type);
+}
+
+MpResult mp_management_get_add_package_request(uint8_t *buffer, uint16_t *size)
+{
+    if (!buffer || !size)
+    {
+        return MP_INVALID_PARAMETER;
+    }
+    return g_mpManagement->getAddPackageRequest(buffer, *size);
+}
+
+MpResult mp_management_set_server_response(const uint8_t *buffer, uint16_t size)
+{
+    if (!buffer)
+    {
+        return MP_INVALID_PARAMETER;
+    }
+    return g_mpManagement->setServerResponse(buffer, size);
+}
+
 void mp_management_terminate()
 {
     if (g_mpManagement)
@@ -64,6 +64,17 @@ public:
     // Sets the machine registration status to completed.
     virtual MpResult setRegistrationStatusAsComplete();
 
+    // Retrieves the type of the pending request generated by the BIOS, or MP_REQ_NONE.
+    virtual MpResult getRequestType(MpRequestType &type);
+
+    // Retrieves AddPackage request.
+    // if AddPackage UEFI are ready for reading, copies the AddPackage request to input buffer.
+    // if not, returns an appropriate error (MP_NO_PENDING_DATA). populates buffer_size with the required size in case of insufficient size.
+    virtual MpResult getAddPackageRequest(uint8_t *buffer, uint16_t &buffer_size);
+
+    // Sets the SGX Registration Server response of the pending request, to be consumed by the BIOS at the next boot.
+    virtual MpResult setServerResponse(const uint8_t *buffer, uint16_t buffer_size);
+
     virtual ~MPManagement();
 
 private:
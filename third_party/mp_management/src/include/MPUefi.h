@@ -113,6 +113,22 @@ public:
      */
     MpResult setRegistrationStatus(const MpRegistrationStatus &status);
 
+    /**
+     * Sets the response of the SGX Registration Server to a pending request.
+     * The BIOS consumes the response at the next boot, e.g. the key blobs returned for an AddPackage request.
+     *
+     * @param response      - input parameter, holds the response buffer.
+     * @param responseSize  - input parameter, size of the response buffer in bytes.
+     *
+     * @return status code, one of:
+     *      - MP_SUCCESS
+     *      - MP_INVALID_PARAMETER
+     *      - MP_MEM_ERROR
+     *      - MP_INSUFFICIENT_PRIVILEGES
+     *      - MP_UEFI_INTERNAL_ERROR
+     */
+    MpResult setServerResponse(const uint8_t *response, const uint16_t &responseSize);
+
     /**
      * MPUefi class destructor
      */
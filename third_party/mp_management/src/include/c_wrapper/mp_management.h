@@ -47,6 +47,9 @@ extern "C"
     MpResult mp_management_get_platform_manifest(uint8_t *buffer, uint16_t *size);
     MpResult mp_management_get_registration_status(MpMachineRegistrationStatus *status);
     MpResult mp_management_set_registration_status_as_complete();
+    MpResult mp_management_get_request_type(MpRequestType *type);
+    MpResult mp_management_get_add_package_request(uint8_t *buffer, uint16_t *size);
+    MpResult mp_management_set_server_response(const uint8_t *buffer, uint16_t size);
     void mp_management_terminate();
 
 #ifdef __cplusplus